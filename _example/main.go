@@ -45,9 +45,9 @@ func main() {
 	recordsAdded, err := provider.AppendRecords(ctx, zone, []libdns.Record{
 		libdns.Address{Name: "appendtest1", IP: netip.MustParseAddr("8.8.4.4"), TTL: time.Duration(123) * time.Second},
 		libdns.Address{Name: "appendtest2", IP: netip.MustParseAddr("2a00:1098:0:80:1000:3b:1:1"), TTL: time.Duration(123) * time.Second},
-		libdns.RR{Name: "appendtest3", Type: "ANAME", Data: "www.google.co.uk.", TTL: time.Duration(999) * time.Second},
+		mythicbeasts.ANAME{Name: "appendtest3", Target: "www.google.co.uk.", TTL: time.Duration(999) * time.Second},
 		libdns.CNAME{Name: "appendtest4", Target: "www.example.com.", TTL: time.Duration(666) * time.Second},
-		libdns.RR{Name: "appendtest5", Type: "DNAME", Data: "www.google.co.uk.", TTL: time.Duration(999) * time.Second},
+		mythicbeasts.DNAME{Name: "appendtest5", Target: "www.google.co.uk.", TTL: time.Duration(999) * time.Second},
 		libdns.NS{Name: "appendtest6", Target: "ns1.mythic-beasts.com.", TTL: time.Duration(999) * time.Second},
 		libdns.RR{Name: "appendtest7", Type: "PTR", Data: "test.example.com.", TTL: time.Duration(999) * time.Second},
 		libdns.TXT{Name: "appendtest8", Text: "This is a test record", TTL: time.Duration(999) * time.Second},
@@ -55,8 +55,8 @@ func main() {
 		libdns.MX{Name: "appendtest10", Target: "mail2.example.com.", Preference: 20, TTL: time.Duration(999) * time.Second},
 		libdns.CAA{Name: "appendtest11", Flags: 128, Tag: "issue", Value: "letsencrypt.org", TTL: time.Duration(999) * time.Second},
 		libdns.SRV{Service: "sip", Transport: "tcp", Name: "appendtest12", Target: "srv.example.com.", Port: 443, Priority: 10, Weight: 5, TTL: time.Duration(999) * time.Second},
-		libdns.RR{Name: "appendtest13", Type: "SSHFP", Data: "3 2 abc1234abc", TTL: time.Duration(999) * time.Second},
-		libdns.RR{Name: "appendtest14", Type: "TLSA", Data: "2 1 2 dab111caba", TTL: time.Duration(999) * time.Second},
+		libdns.RR{Name: "appendtest13", Type: "SSHFP", Data: "4 2 123456789abcdef67890123456789abcdef67890123456789abcdef6789abcde", TTL: time.Duration(999) * time.Second},
+		libdns.RR{Name: "appendtest14", Type: "TLSA", Data: "2 1 1 dab111cabadab111cabadab111cabadab111cabadab111cabadab111cabaabcd", TTL: time.Duration(999) * time.Second},
 	})
 	if err != nil {
 		fmt.Printf("ERROR: %s\n", err.Error())
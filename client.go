@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,152 +17,390 @@ import (
 	"github.com/libdns/libdns"
 )
 
-var (
-	apiURL  = "https://api.mythic-beasts.com/dns/v2"
-	authURL = "https://auth.mythic-beasts.com/login"
+const (
+	defaultAPIURL  = "https://api.mythic-beasts.com/dns/v2"
+	defaultAuthURL = "https://auth.mythic-beasts.com/login"
 )
 
+// defaultHTTPClient is used when Provider.HTTPClient is nil. Unlike
+// http.DefaultClient it does not wait indefinitely for a response.
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// httpClient returns the client used for auth and DNS API requests.
+func (p *Provider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// apiURL returns the base URL of the DNS API.
+func (p *Provider) apiURL() string {
+	if p.APIURL != "" {
+		return strings.TrimSuffix(p.APIURL, "/")
+	}
+	return defaultAPIURL
+}
+
+// authURL returns the URL of the token endpoint.
+func (p *Provider) authURL() string {
+	if p.AuthURL != "" {
+		return p.AuthURL
+	}
+	return defaultAuthURL
+}
+
+// tokenStore returns the store used to share tokens, which unless configured
+// is private to this Provider.
+func (p *Provider) tokenStore() TokenStore {
+	if p.TokenStore != nil {
+		return p.TokenStore
+	}
+	return &p.memoryTokens
+}
+
+// currentToken returns the token held by the Provider.
+func (p *Provider) currentToken() Token {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+
+	return p.tokenLocked()
+}
+
+// tokenLocked is currentToken for callers holding tokenMutex.
+func (p *Provider) tokenLocked() Token {
+	return Token{AccessToken: p.token.Token, ExpiresAt: p.tokenExpiresAt}
+}
+
+// setToken replaces the token held by the Provider.
+func (p *Provider) setToken(token Token) {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+
+	p.token = mythicAuthResponse{Token: token.AccessToken, TokenType: "bearer"}
+	p.tokenExpiresAt = token.ExpiresAt
+}
+
+// invalidateToken forgets a token rejected by the API, both here and in the
+// token store, unless it has already been replaced.
+func (p *Provider) invalidateToken(ctx context.Context, rejected string) {
+	p.tokenMutex.Lock()
+	if p.token.Token == rejected {
+		p.token = mythicAuthResponse{}
+		p.tokenExpiresAt = time.Time{}
+	}
+	p.tokenMutex.Unlock()
+
+	store := p.tokenStore()
+	if stored, ok, err := store.LoadToken(ctx, p.KeyID); err == nil && ok && stored.AccessToken == rejected {
+		_ = store.DeleteToken(ctx, p.KeyID)
+	}
+}
+
+// loginCall is a login in progress, shared by every caller which needs a
+// token while it runs.
+type loginCall struct {
+	done chan struct{} // Closed once err is set
+	err  error
+}
+
 // Logs into mythic beasts to acquire a bearer token for use in future API calls.
 // https://www.mythic-beasts.com/support/api/auth#sec-obtaining-a-token
 func (p *Provider) login(ctx context.Context) error {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	for {
+		p.tokenMutex.Lock()
+		// Check if token is present and valid (with 30s buffer)
+		if p.tokenLocked().valid() {
+			p.tokenMutex.Unlock()
+			return nil
+		}
+
+		// Only one login is made at a time; other callers wait for it and
+		// then use the token it obtained. Requests with a valid token are
+		// not held up by a login.
+		call := p.loginCall
+		if call == nil {
+			call = &loginCall{done: make(chan struct{})}
+			p.loginCall = call
+			p.tokenMutex.Unlock()
+
+			call.err = p.fetchToken(ctx)
+
+			p.tokenMutex.Lock()
+			p.loginCall = nil
+			p.tokenMutex.Unlock()
+			close(call.done)
+			return call.err
+		}
+		p.tokenMutex.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// A login abandoned because its caller's context ended says nothing
+		// about ours, so try again.
+		if call.err != nil && !isContextError(call.err) {
+			return call.err
+		}
+	}
+}
 
-	// Check if token is present and valid (with 30s buffer)
-	if p.token.Token != "" && time.Now().Add(30*time.Second).Before(p.tokenExpiresAt) {
+// isContextError reports whether err is due to a cancelled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetchToken obtains a token, from the token store if it holds a valid one
+// or otherwise from the auth API. It is only called by login.
+func (p *Provider) fetchToken(ctx context.Context) error {
+	// Hold the store's login lock, if it has one, until a new token has
+	// been stored, so that other Providers or processes waiting for it use
+	// that token rather than logging in as well.
+	store := p.tokenStore()
+	if locker, ok := store.(TokenLocker); ok {
+		unlock, err := locker.LockToken(ctx, p.KeyID)
+		if isContextError(err) {
+			return err
+		}
+		if err != nil {
+			p.log(ctx, slog.LevelWarn, "mythicbeasts: failed to lock token store", slog.String("key_id", p.KeyID), slog.Any("error", err))
+		} else {
+			defer unlock()
+		}
+	}
+
+	// Reuse a token obtained by another Provider or process if possible.
+	// The store is only a cache, so failing to read it is not fatal.
+	stored, ok, err := store.LoadToken(ctx, p.KeyID)
+	if err != nil {
+		p.log(ctx, slog.LevelWarn, "mythicbeasts: failed to load token from store", slog.String("key_id", p.KeyID), slog.Any("error", err))
+	} else if ok && stored.valid() {
+		p.log(ctx, slog.LevelDebug, "mythicbeasts: using token from store", slog.String("key_id", p.KeyID), slog.Time("expires_at", stored.ExpiresAt))
+		p.setToken(stored)
 		return nil
 	}
 
+	start := time.Now()
+	token, status, err := p.requestToken(ctx)
+	args := []any{slog.String("key_id", p.KeyID)}
+	if status != 0 {
+		args = append(args, slog.Int("status", status))
+	}
+	args = append(args, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		p.log(ctx, slog.LevelWarn, "mythicbeasts: login failed", append(args, slog.Any("error", err))...)
+		return err
+	}
+	p.log(ctx, slog.LevelInfo, "mythicbeasts: logged in", append(args, slog.Time("expires_at", token.ExpiresAt))...)
+	p.setToken(token)
+
+	// As above, the store is only a cache; the token is usable regardless.
+	err = store.StoreToken(ctx, p.KeyID, token)
+	if err != nil {
+		p.log(ctx, slog.LevelWarn, "mythicbeasts: failed to save token to store", slog.String("key_id", p.KeyID), slog.Any("error", err))
+	}
+
+	// Success
+	return nil
+}
+
+// requestToken obtains a new token from the auth API. It also returns the
+// HTTP status, or 0 if no response was received.
+func (p *Provider) requestToken(ctx context.Context) (Token, int, error) {
 	params := url.Values{}
 	params.Add("grant_type", `client_credentials`)
 	reqBody := strings.NewReader(params.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, "POST", authURL, reqBody)
+	req, err := http.NewRequestWithContext(ctx, "POST", p.authURL(), reqBody)
 	if err != nil {
-		return fmt.Errorf("login: unknown error when creating http.NewRequest()")
+		return Token{}, 0, fmt.Errorf("login: creating request: %w", err)
 	}
 	req.SetBasicAuth(p.KeyID, p.Secret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	err = p.waitRateLimit(ctx)
 	if err != nil {
-		return fmt.Errorf("login: unknown auth error")
+		return Token{}, 0, fmt.Errorf("login: waiting for rate limit: %w", err)
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
 
-		}
-	}(resp.Body)
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return Token{}, 0, fmt.Errorf("login: %w", err)
+	}
+	defer p.closeBody(ctx, resp.Body)
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("login: %w", err)
+		return Token{}, resp.StatusCode, fmt.Errorf("login: %w", err)
 	}
 
 	if resp.StatusCode != 200 {
-		if resp.StatusCode < 400 || resp.StatusCode > 499 {
-			return fmt.Errorf("login: unknown error in auth API: %d", resp.StatusCode)
-		}
+		apiErr := &APIError{StatusCode: resp.StatusCode, Op: "login"}
 
 		errResp := &mythicAuthResponseError{}
-		err = json.Unmarshal(body, errResp)
-		if err != nil {
-			return fmt.Errorf("login: error parsing error: %w", err)
+		if json.Unmarshal(body, errResp) == nil {
+			for _, msg := range []string{errResp.ErrorMessage, errResp.ErrorDescription} {
+				if msg != "" {
+					apiErr.Messages = append(apiErr.Messages, msg)
+				}
+			}
 		}
 
-		return fmt.Errorf("login: %d: %s", resp.StatusCode, errResp.ErrorMessage+errResp.ErrorDescription)
+		return Token{}, resp.StatusCode, fmt.Errorf("login: %w", apiErr)
 	}
 
 	authResp := mythicAuthResponse{}
 	err = json.Unmarshal(body, &authResp)
 	if err != nil {
-		return fmt.Errorf("login: error parsing response: %w", err)
+		return Token{}, resp.StatusCode, fmt.Errorf("login: error parsing response: %w", err)
 	}
 
 	if authResp.TokenType != "bearer" {
-		return fmt.Errorf("login: received unexpected token type: %s", authResp.TokenType)
+		return Token{}, resp.StatusCode, fmt.Errorf("login: received unexpected token type: %s", authResp.TokenType)
 	}
 
-	p.token = authResp
-	// Set expiration time based on Lifetime (in seconds). Default to a safe fallback if 0?
-	// Specs usually say expires_in.
+	// Tokens without a lifetime are assumed to last an hour.
+	lifetime := time.Hour
 	if authResp.Lifetime > 0 {
-		p.tokenExpiresAt = time.Now().Add(time.Duration(authResp.Lifetime) * time.Second)
-	} else {
-		// Fallback or assume indefinitely? Let's check docs or be safe.
-		// If 0, maybe it doesn't expire. But let's assume it does to be safe, e.g. 1 hour.
-		p.tokenExpiresAt = time.Now().Add(1 * time.Hour)
+		lifetime = time.Duration(authResp.Lifetime) * time.Second
 	}
+	return Token{AccessToken: authResp.Token, ExpiresAt: time.Now().Add(lifetime)}, resp.StatusCode, nil
+}
 
-	// Success
-	return nil
+// closeBody closes a response body, which has already been read. Failing to
+// do so only matters to the connection pool, so is logged rather than
+// returned.
+func (p *Provider) closeBody(ctx context.Context, body io.Closer) {
+	err := body.Close()
+	if err != nil {
+		p.log(ctx, slog.LevelDebug, "mythicbeasts: failed to close response body", slog.Any("error", err))
+	}
+}
+
+// doAPIRequest handles the common logic for making authenticated API requests.
+// Idempotent requests are retried with backoff on connection errors, 429 and
+// 5xx responses; see retry.go. If the token is rejected with a 401, the
+// provider logs in again and replays the request once, whatever its method,
+// as the API has not acted on it.
+func (p *Provider) doAPIRequest(ctx context.Context, op, zone, method, url string, payload []byte) ([]byte, error) {
+	return p.doAPIRequestWithClient(ctx, p.httpClient(), op, zone, method, url, payload)
+}
+
+// doAPIRequestWithClient is like doAPIRequest, but makes the request with
+// client in place of the provider's usual HTTP client. Logins still use the
+// usual client.
+func (p *Provider) doAPIRequestWithClient(ctx context.Context, client *http.Client, op, zone, method, url string, payload []byte) ([]byte, error) {
+	retries := 0
+	if isIdempotent(method) {
+		retries = p.maxRetries()
+	}
+
+	reauthenticated := false
+	for attempt := 0; ; {
+		err := p.login(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("login: provider login failed: %w", err)
+		}
+
+		err = p.waitRateLimit(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for rate limit: %w", err)
+		}
+
+		respBody, status, retryAfter, err := p.doAPIAttempt(ctx, client, op, zone, method, url, payload)
+		if err == nil {
+			return respBody, nil
+		}
+		if status == 401 && !reauthenticated {
+			p.log(ctx, slog.LevelInfo, "mythicbeasts: token rejected, logging in again", slog.String("op", op))
+			reauthenticated = true
+			continue
+		}
+		if attempt >= retries || !isRetryable(ctx, status) {
+			return nil, err
+		}
+
+		delay := p.retryDelay(attempt, retryAfter)
+		p.log(ctx, slog.LevelInfo, "mythicbeasts: retrying request", slog.String("op", op), slog.Int("attempt", attempt+1), slog.Duration("delay", delay))
+		if retryAfter > p.maxRetryAfter() || !sleepContext(ctx, delay) {
+			return nil, err
+		}
+		attempt++
+	}
 }
 
-// doAPIRequest handles the common logic for making authenticated API requests
-func (p *Provider) doAPIRequest(ctx context.Context, method, url string, body io.Reader) ([]byte, error) {
+// doAPIAttempt makes a single API request using client. It returns the HTTP
+// status, or 0 if no response was received, and the delay requested by any
+// Retry-After header alongside the usual results.
+func (p *Provider) doAPIAttempt(ctx context.Context, client *http.Client, op, zone, method, url string, payload []byte) ([]byte, int, time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("NewRequestWithContext: %s", err.Error())
+		return nil, 0, 0, fmt.Errorf("NewRequestWithContext: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.token.Token)
+	bearer := p.currentToken().AccessToken
+	req.Header.Set("Authorization", "Bearer "+bearer)
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http.DefaultClient.Do: %s", err.Error())
+		err = fmt.Errorf("httpClient.Do: %w", err)
+		p.logRequest(ctx, op, zone, req, 0, start, nil, err)
+		return nil, 0, 0, err
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
+	defer p.closeBody(ctx, resp.Body)
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
+		err = fmt.Errorf("ioutil.ReadAll: %w", err)
+		p.logRequest(ctx, op, zone, req, resp.StatusCode, start, nil, err)
+		return nil, 0, 0, err
 	}
 
 	if resp.StatusCode != 200 {
 		if resp.StatusCode == 401 {
-			p.token.Token = ""
-			p.tokenExpiresAt = time.Time{}
+			p.invalidateToken(ctx, bearer)
 		}
 
-		errResp := &mythicError{}
-		errorsResp := &mythicErrors{}
-
-		err := json.Unmarshal(respBody, errorsResp)
-		if err != nil {
-			err := json.Unmarshal(respBody, errResp)
-			if err != nil {
-				return nil, fmt.Errorf("api error: %d", resp.StatusCode)
-			}
-			return nil, fmt.Errorf("%d: %s", resp.StatusCode, errResp.Error)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Op:         op,
+			Zone:       zone,
+			Messages:   parseErrorMessages(respBody),
 		}
-		return nil, fmt.Errorf("%d: %s", resp.StatusCode, errorsResp.Errors)
+		p.logRequest(ctx, op, zone, req, resp.StatusCode, start, nil, apiErr)
+		return nil, resp.StatusCode, retryAfter, apiErr
 	}
 
-	return respBody, nil
+	p.logRequest(ctx, op, zone, req, resp.StatusCode, start, respBody, nil)
+	return respBody, resp.StatusCode, 0, nil
 }
 
-func (p *Provider) addRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// addRecords adds records, already converted to data by FromLibdns.
+func (p *Provider) addRecords(ctx context.Context, zone hostedZone, records []libdns.Record, data mythicRecords) ([]libdns.Record, error) {
+	unlock := p.lockZone(zone.Name)
+	defer unlock()
 
 	var addedRecords []libdns.Record
 
-	data := mythicRecords{}
-	var err = data.FromLibdns(records)
-	if err != nil {
-		return nil, fmt.Errorf("addRecords: Error converting libdns record to mythic record: %s", err.Error())
-	}
+	zone.toHosts(data.Records)
 
 	payload, err := json.Marshal(data)
 	if err != nil {
-		return nil, fmt.Errorf("addRecords: Error creating JSON payload: %s", err.Error())
+		return nil, fmt.Errorf("addRecords: Error creating JSON payload: %w", err)
 	}
 
-	respBody, err := p.doAPIRequest(ctx, "POST", apiURL+"/zones/"+url.PathEscape(zone)+"/records", bytes.NewReader(payload))
+	respBody, err := p.doAPIRequest(ctx, "addRecords", zone.Name, "POST", p.apiURL()+"/zones/"+url.PathEscape(zone.Name)+"/records", payload)
 	if err != nil {
 		return nil, fmt.Errorf("addRecords: %w", err)
 	}
@@ -171,30 +411,60 @@ func (p *Provider) addRecords(ctx context.Context, zone string, records []libdns
 		return nil, fmt.Errorf("addRecords: error parsing response: %w", err)
 	}
 
-	// Assuming all were added if successful.
-	addedRecords = append(addedRecords, records...)
-	return addedRecords, nil
+	// Read the records back even if the API added fewer than expected, so
+	// that the caller can see what was stored alongside the error.
+	var mismatch error
+	if appendResp.RecordsAdded != len(data.Records) {
+		mismatch = fmt.Errorf("addRecords: API added %d records, expected %d", appendResp.RecordsAdded, len(data.Records))
+	}
+
+	stored, err := p.readBack(ctx, zone, data.Records)
+	if err != nil {
+		if mismatch != nil {
+			return nil, fmt.Errorf("%w; %w", mismatch, err)
+		}
+		return nil, fmt.Errorf("addRecords: %w", err)
+	}
+
+	// Return the stored version of each input record, so that values
+	// applied by the API such as the default TTL are reflected.
+	claimed := make([]bool, len(stored))
+	for _, record := range records {
+		rr := canonicalRR(record)
+		found := false
+		for i, s := range stored {
+			if claimed[i] || !sameRecord(recordRR(s), rr) {
+				continue
+			}
+			claimed[i] = true
+			addedRecords = append(addedRecords, s)
+			found = true
+			break
+		}
+		if !found && mismatch == nil {
+			return nil, fmt.Errorf("addRecords: record %s %s %q missing from zone after adding", rr.Name, rr.Type, rr.Data)
+		}
+	}
+	return addedRecords, mismatch
 }
 
-func (p *Provider) setRecordsAtomic(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// setRecordsAtomic replaces the records at each name and type in data,
+// which has been converted by FromLibdns.
+func (p *Provider) setRecordsAtomic(ctx context.Context, zone hostedZone, data mythicRecords) ([]libdns.Record, error) {
+	unlock := p.lockZone(zone.Name)
+	defer unlock()
 
 	var setRecords []libdns.Record
 
-	if len(records) == 0 {
+	if len(data.Records) == 0 {
 		return setRecords, nil
 	}
 
-	data := mythicRecords{}
-	var err = data.FromLibdns(records)
-	if err != nil {
-		return nil, fmt.Errorf("setRecordsAtomic: Error converting libdns records to mythic records: %s", err.Error())
-	}
+	zone.toHosts(data.Records)
 
 	payload, err := json.Marshal(data)
 	if err != nil {
-		return nil, fmt.Errorf("setRecordsAtomic: Error creating JSON payload: %s", err.Error())
+		return nil, fmt.Errorf("setRecordsAtomic: Error creating JSON payload: %w", err)
 	}
 
 	// Build query parameters for atomic replacement
@@ -202,29 +472,26 @@ func (p *Provider) setRecordsAtomic(ctx context.Context, zone string, records []
 	values := url.Values{}
 	seen := make(map[string]bool)
 
-	for _, rec := range records {
-		rr := rec.RR()
-		// Determine host relative to zone.
-		// SetRecords guarantees Name is relative to zone.
-		host := rr.Name
+	for _, rec := range data.Records {
+		// Hosts have already been made relative to the hosted zone.
+		host := rec.GetName()
+		if host == "" {
+			host = "@"
+		}
 
-		key := host + "|" + rr.Type
+		key := host + "|" + rec.GetType()
 		if seen[key] {
 			continue
 		}
 		seen[key] = true
 
-		if host == "" || host == "@" {
-			host = "@"
-		}
-
-		val := fmt.Sprintf("host=%s&type=%s", host, rr.Type)
+		val := fmt.Sprintf("host=%s&type=%s", host, rec.GetType())
 		values.Add("select", val)
 	}
 
-	reqURL := apiURL + "/zones/" + url.PathEscape(zone) + "/records?" + values.Encode()
+	reqURL := p.apiURL() + "/zones/" + url.PathEscape(zone.Name) + "/records?" + values.Encode()
 
-	respBody, err := p.doAPIRequest(ctx, "PUT", reqURL, bytes.NewReader(payload))
+	respBody, err := p.doAPIRequest(ctx, "setRecordsAtomic", zone.Name, "PUT", reqURL, payload)
 	if err != nil {
 		return nil, fmt.Errorf("setRecordsAtomic: %w", err)
 	}
@@ -235,40 +502,393 @@ func (p *Provider) setRecordsAtomic(ctx context.Context, zone string, records []
 		return nil, fmt.Errorf("setRecordsAtomic: error parsing response: %w", err)
 	}
 
-	setRecords = append(setRecords, records...)
-	return setRecords, nil
+	var mismatch error
+	if appendResp.RecordsAdded != len(data.Records) {
+		mismatch = fmt.Errorf("setRecordsAtomic: API added %d records, expected %d", appendResp.RecordsAdded, len(data.Records))
+	}
+
+	setRecords, err = p.readBack(ctx, zone, data.Records)
+	if err != nil {
+		if mismatch != nil {
+			return nil, fmt.Errorf("%w; %w", mismatch, err)
+		}
+		return nil, fmt.Errorf("setRecordsAtomic: %w", err)
+	}
+	return setRecords, mismatch
+}
+
+// readBack fetches the records stored for each host/type pair in records,
+// with names relative to the requested zone.
+func (p *Provider) readBack(ctx context.Context, zone hostedZone, records []mythicRecordType) ([]libdns.Record, error) {
+	var stored []libdns.Record
+	seen := make(map[string]bool)
+
+	for _, rec := range records {
+		key := rec.GetName() + "|" + rec.GetType()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		current, err := p.getRecordsAt(ctx, zone, rec.GetName(), rec.GetType())
+		if err != nil {
+			return nil, fmt.Errorf("readBack: %w", err)
+		}
+
+		for _, r := range zone.fromHosts(current) {
+			record, err := r.GetLibdnsRecord()
+			if err != nil {
+				return nil, fmt.Errorf("readBack: failed to parse record %s: %w", r.GetName(), err)
+			}
+			stored = append(stored, record)
+		}
+	}
+	return stored, nil
+}
+
+// recordQuery selects the records fetched by queryRecords. An empty host or
+// type matches any. If selects is given, only records matching one of its
+// select options are fetched.
+type recordQuery struct {
+	host             string
+	recType          string
+	selects          []url.Values
+	excludeTemplate  bool
+	excludeGenerated bool
 }
 
-func (p *Provider) removeRecord(ctx context.Context, zone string, record libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// queryRecords fetches the records in zone selected by q, using the
+// /records/{host}/{type} paths where possible.
+func (p *Provider) queryRecords(ctx context.Context, op string, zone hostedZone, q recordQuery) ([]mythicRecordType, error) {
+	reqURL := p.apiURL() + "/zones/" + url.PathEscape(zone.Name) + "/records"
+	var params []string
 
-	var removedRecords []libdns.Record
+	if q.host != "" {
+		reqURL += "/" + url.PathEscape(q.host)
+		if q.recType != "" {
+			reqURL += "/" + url.PathEscape(q.recType)
+		}
+	} else if q.recType != "" {
+		params = append(params, url.Values{"select": {"type=" + q.recType}}.Encode())
+	}
+	for _, selector := range q.selects {
+		params = append(params, url.Values{"select": {selector.Encode()}}.Encode())
+	}
+	if q.excludeTemplate {
+		params = append(params, "exclude-template")
+	}
+	if q.excludeGenerated {
+		params = append(params, "exclude-generated")
+	}
+	if len(params) > 0 {
+		reqURL += "?" + strings.Join(params, "&")
+	}
 
-	data := mythicRecords{}
-	var err = data.FromLibdns([]libdns.Record{record})
+	respBody, err := p.doAPIRequest(ctx, op, zone.Name, "GET", reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("removeRecord: Error converting libdns record to mythic record: %s", err.Error())
+		return nil, err
 	}
 
-	reqURL := apiURL + "/zones/" + url.PathEscape(zone) + "/records/" +
-		url.PathEscape(data.Records[0].GetName()) + "/" +
-		url.PathEscape(data.Records[0].GetType()) +
-		"?exclude-template&exclude-generated"
+	result := mythicRecords{}
+	err = result.UnmarshalJSON(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return result.Records, nil
+}
 
-	respBody, err := p.doAPIRequest(ctx, "DELETE", reqURL, nil)
+// getRecordsAt fetches the user records at host in zone, limited to
+// recType unless it is empty.
+func (p *Provider) getRecordsAt(ctx context.Context, zone hostedZone, host, recType string) ([]mythicRecordType, error) {
+	records, err := p.queryRecords(ctx, "getRecordsAt", zone, recordQuery{
+		host:             host,
+		recType:          recType,
+		excludeTemplate:  true,
+		excludeGenerated: true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("removeRecord: %w", err)
+		return nil, fmt.Errorf("getRecordsAt: %w", err)
 	}
+	return records, nil
+}
 
-	appendResp := mythicRecordUpdate{}
-	err = json.Unmarshal(respBody, &appendResp)
+// sameRecord reports whether a and b have the same name, type and data,
+// regardless of TTL.
+func sameRecord(a, b libdns.RR) bool {
+	return strings.EqualFold(normalizeName(a.Name), normalizeName(b.Name)) &&
+		a.Type == b.Type && sameData(a.Data, b.Data)
+}
+
+// canonicalRR returns record in the form it is sent to the API and listed
+// back, for example with an IPv6 address in its canonical form or spaces
+// removed from hex data, so that it can be compared with stored records.
+// Records which cannot be converted, such as delete inputs with an empty
+// type or data, are returned unchanged.
+func canonicalRR(record libdns.Record) libdns.RR {
+	mr, err := fromLibdns(record)
+	if err != nil {
+		return recordRR(record)
+	}
+	stored, err := mr.GetLibdnsRecord()
 	if err != nil {
-		return nil, fmt.Errorf("removeRecord: error parsing response: %w", err)
+		return recordRR(record)
+	}
+	return recordRR(stored)
+}
+
+// normalizeName maps the empty name to "@", the name of the zone apex.
+func normalizeName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
+
+// sameData reports whether two RR data strings describe the same value,
+// ignoring a trailing dot on a final hostname field.
+func sameData(a, b string) bool {
+	return strings.TrimSuffix(a, ".") == strings.TrimSuffix(b, ".")
+}
+
+// matchesDelete reports whether current is selected by the delete input rr,
+// where an empty type, data or TTL in rr matches any value.
+func matchesDelete(current, rr libdns.RR) bool {
+	if !strings.EqualFold(normalizeName(current.Name), normalizeName(rr.Name)) {
+		return false
 	}
+	if rr.Type != "" && current.Type != rr.Type {
+		return false
+	}
+	if rr.Data != "" && !sameData(current.Data, rr.Data) {
+		return false
+	}
+	if rr.TTL != 0 && current.TTL != rr.TTL {
+		return false
+	}
+	return true
+}
 
-	if appendResp.RecordsRemoved == 1 {
-		removedRecords = append(removedRecords, record)
+// removeRecords deletes the records in the zone matching any of records.
+// The records at the names and types being deleted are fetched with one
+// request, and the matches deleted with another, each selecting all of them
+// unless there are too many for one URL; see batchSelects. Only if the API
+// reports removing a different number of records are they fetched again, to
+// find out which remain. Records which could not be deleted, or which were
+// removed without being asked for, are reported in a *DeleteError.
+func (p *Provider) removeRecords(ctx context.Context, zone hostedZone, records []libdns.Record) ([]libdns.Record, error) {
+	unlock := p.lockZone(zone.Name)
+	defer unlock()
+
+	// Inputs are compared with the zone in the form they would be stored.
+	inputs := make([]libdns.RR, len(records))
+	anyType := make(map[string]bool)
+	for i, record := range records {
+		rr := canonicalRR(record)
+		rr.Name = normalizeName(rr.Name)
+		inputs[i] = rr
+		if rr.Type == "" {
+			anyType[strings.ToLower(zone.host(rr.Name))] = true
+		}
+	}
+
+	// An input without a type matches every record at its name, so the
+	// whole name is fetched rather than each type at it.
+	var targets []url.Values
+	fetched := make(map[string]bool)
+	for _, rr := range inputs {
+		host := strings.ToLower(zone.host(rr.Name))
+		target := url.Values{"host": {host}}
+		if !anyType[host] {
+			target.Set("type", rr.Type)
+		}
+		if key := target.Encode(); !fetched[key] {
+			fetched[key] = true
+			targets = append(targets, target)
+		}
+	}
+
+	mythic, current, err := p.fetchSelected(ctx, zone, targets)
+	if err != nil {
+		return nil, fmt.Errorf("removeRecords: %w", err)
+	}
+
+	var matched []int
+	claimed := make([]bool, len(current))
+	for _, rr := range inputs {
+		for i, c := range current {
+			if claimed[i] || !matchesDelete(recordRR(c), rr) {
+				continue
+			}
+			claimed[i] = true
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	// Each record is selected by all of its fields, so that records which
+	// share data but differ in, say, MX priority or CAA tag are kept.
+	var selects []url.Values
+	seen := make(map[string]bool)
+	for _, i := range matched {
+		selector := hostSelector(mythic[i])
+		if key := selector.Encode(); !seen[key] {
+			seen[key] = true
+			selects = append(selects, selector)
+		}
+	}
+
+	removedCount := 0
+	batchErrs := make(map[string]error) // By selector, for requests which failed
+	for _, batch := range batchSelects(selects) {
+		n, err := p.deleteSelected(ctx, zone, batch)
+		if err != nil {
+			for _, selector := range batch {
+				batchErrs[selector.Encode()] = err
+			}
+			continue
+		}
+		removedCount += n
+	}
+
+	var removedRecords []libdns.Record
+	if len(batchErrs) == 0 && removedCount == len(matched) {
+		for _, i := range matched {
+			removedRecords = append(removedRecords, current[i])
+		}
+		return removedRecords, nil
+	}
+
+	// A request failed or the API removed more or fewer records than
+	// expected, so check which of the fetched records remain.
+	deleteErr := &DeleteError{}
+	remaining, _, err := p.fetchSelected(ctx, zone, targets)
+	if err != nil {
+		for _, i := range matched {
+			failErr := batchErrs[hostSelector(mythic[i]).Encode()]
+			if failErr == nil {
+				failErr = fmt.Errorf("removed %d of %d records: %w", removedCount, len(matched), err)
+			}
+			deleteErr.Failed = append(deleteErr.Failed, FailedDeletion{Record: current[i], Err: failErr})
+		}
+		return nil, deleteErr
+	}
+
+	present := make(map[string]bool)
+	for _, r := range remaining {
+		present[hostSelector(r).Encode()] = true
+	}
+
+	for _, i := range matched {
+		key := hostSelector(mythic[i]).Encode()
+		if !present[key] {
+			removedRecords = append(removedRecords, current[i])
+			continue
+		}
+		failErr := batchErrs[key]
+		if failErr == nil {
+			failErr = fmt.Errorf("record was not removed")
+		}
+		deleteErr.Failed = append(deleteErr.Failed, FailedDeletion{Record: current[i], Err: failErr})
+	}
+	for i, c := range current {
+		if !claimed[i] && !present[hostSelector(mythic[i]).Encode()] {
+			deleteErr.Unexpected = append(deleteErr.Unexpected, c)
+		}
+	}
+
+	if len(deleteErr.Failed) > 0 || len(deleteErr.Unexpected) > 0 {
+		return removedRecords, deleteErr
 	}
 	return removedRecords, nil
 }
+
+// hostSelector returns the select option which identifies r in its zone.
+func hostSelector(r mythicRecordType) url.Values {
+	selector := r.selector()
+	selector.Set("host", r.GetName())
+	return selector
+}
+
+// maxSelectLength bounds the length of the select options in one request,
+// keeping its URL within the limits commonly applied by web servers.
+const maxSelectLength = 6000
+
+// batchSelects splits selects into batches whose options fit within
+// maxSelectLength, each of which can be sent in one request.
+func batchSelects(selects []url.Values) [][]url.Values {
+	var batches [][]url.Values
+	var batch []url.Values
+	length := 0
+
+	for _, selector := range selects {
+		n := len(url.Values{"select": {selector.Encode()}}.Encode()) + 1
+		if len(batch) > 0 && length+n > maxSelectLength {
+			batches = append(batches, batch)
+			batch, length = nil, 0
+		}
+		batch = append(batch, selector)
+		length += n
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// fetchSelected fetches the user records in zone matching any of selects,
+// returning them as given by the API, with hosts relative to the hosted
+// zone, and as libdns records relative to the requested zone.
+func (p *Provider) fetchSelected(ctx context.Context, zone hostedZone, selects []url.Values) ([]mythicRecordType, []libdns.Record, error) {
+	var mythic []mythicRecordType
+	var records []libdns.Record
+
+	for _, batch := range batchSelects(selects) {
+		result, err := p.queryRecords(ctx, "removeRecords", zone, recordQuery{
+			selects:          batch,
+			excludeTemplate:  true,
+			excludeGenerated: true,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, r := range result {
+			name, ok := zone.name(r.GetName())
+			if !ok {
+				continue
+			}
+			record, err := r.withName(name).GetLibdnsRecord()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse record %s: %w", r.GetName(), err)
+			}
+			mythic = append(mythic, r)
+			records = append(records, record)
+		}
+	}
+	return mythic, records, nil
+}
+
+// deleteSelected deletes the user records in zone matching any of selects,
+// returning the number the API removed.
+func (p *Provider) deleteSelected(ctx context.Context, zone hostedZone, selects []url.Values) (int, error) {
+	values := url.Values{}
+	for _, selector := range selects {
+		values.Add("select", selector.Encode())
+	}
+	reqURL := p.apiURL() + "/zones/" + url.PathEscape(zone.Name) + "/records?" +
+		values.Encode() + "&exclude-template&exclude-generated"
+
+	respBody, err := p.doAPIRequest(ctx, "removeRecords", zone.Name, "DELETE", reqURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	deleteResp := mythicRecordUpdate{}
+	err = json.Unmarshal(respBody, &deleteResp)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing response: %w", err)
+	}
+	return deleteResp.RecordsRemoved, nil
+}
@@ -0,0 +1,154 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestReauthentication(t *testing.T) {
+	get := func(p *mythicbeasts.Provider) error {
+		_, err := p.GetRecords(context.Background(), "example.com.")
+		return err
+	}
+	post := func(p *mythicbeasts.Provider) error {
+		_, err := p.AppendRecords(context.Background(), "example.com.", []libdns.Record{libdns.TXT{Name: "www", Text: "x"}})
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		reject       func(*mythicbeaststest.Server)
+		call         func(*mythicbeasts.Provider) error
+		wantErr      error
+		wantLogins   int
+		wantRequests int
+	}{
+		{
+			name:         "GET replayed after revocation",
+			reject:       (*mythicbeaststest.Server).RevokeTokens,
+			call:         get,
+			wantLogins:   1,
+			wantRequests: 2,
+		},
+		{
+			name:   "POST replayed after revocation",
+			reject: (*mythicbeaststest.Server).RevokeTokens,
+			call:   post,
+			// The rejected POST, its replay and the read back.
+			wantLogins:   1,
+			wantRequests: 3,
+		},
+		{
+			name:         "replayed only once",
+			reject:       func(s *mythicbeaststest.Server) { s.FailNext(2, http.StatusUnauthorized) },
+			call:         get,
+			wantErr:      mythicbeasts.ErrUnauthorized,
+			wantLogins:   1,
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+
+			provider := newProvider(srv)
+			if err := get(provider); err != nil {
+				t.Fatalf("GetRecords: %v", err)
+			}
+			logins, requests := srv.Logins(), srv.Requests()
+			tt.reject(srv)
+
+			err := tt.call(provider)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+			if n := srv.Logins() - logins; n != tt.wantLogins {
+				t.Errorf("logged in %d more times, want %d", n, tt.wantLogins)
+			}
+			if n := srv.Requests() - requests; n != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestReplayedPostAddsOnce(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	if _, err := provider.GetRecords(context.Background(), "example.com."); err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	srv.RevokeTokens()
+
+	added, err := provider.AppendRecords(context.Background(), "example.com.", []libdns.Record{libdns.TXT{Name: "www", Text: "x"}})
+	if err != nil || len(added) != 1 {
+		t.Fatalf("AppendRecords returned %d records, %v; want 1", len(added), err)
+	}
+	if records := srv.Records("example.com"); len(records) != 1 {
+		t.Errorf("zone holds %+v, want one record", records)
+	}
+}
+
+func TestConcurrentCallsLogInOnce(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	getAll := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := provider.GetRecords(context.Background(), "example.com."); err != nil {
+					t.Errorf("GetRecords: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	getAll()
+	if logins := srv.Logins(); logins != 1 {
+		t.Errorf("logged in %d times, want 1", logins)
+	}
+
+	// Every caller sees its token rejected, but only one logs in again.
+	srv.RevokeTokens()
+	getAll()
+	if logins := srv.Logins(); logins != 2 {
+		t.Errorf("logged in %d times after revocation, want 2", logins)
+	}
+}
+
+func TestWrongCredentials(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	provider.Secret = "wrong"
+
+	_, err := provider.GetRecords(context.Background(), "example.com.")
+	var apiErr *mythicbeasts.APIError
+	if !errors.Is(err, mythicbeasts.ErrUnauthorized) || !errors.As(err, &apiErr) || apiErr.Op != "login" {
+		t.Errorf("error %v, want an unauthorized login *APIError", err)
+	}
+	if requests := srv.Requests(); requests != 0 {
+		t.Errorf("made %d API requests, want none", requests)
+	}
+}
@@ -0,0 +1,110 @@
+package mythicbeasts
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/libdns/libdns"
+)
+
+// IPVersion selects the address family, and so the record type, updated by
+// UpdateDynamicRecord.
+type IPVersion int
+
+const (
+	IPv4 IPVersion = 4 // Update the A record
+	IPv6 IPVersion = 6 // Update the AAAA record
+)
+
+// UpdateDynamicRecord points name in zone at the public address from which
+// the request is made, using the DNS API's dynamic update endpoint. The
+// request is made over IPv4 or IPv6 according to version, which selects
+// whether the A or AAAA record is set. It returns the record as stored.
+//
+// Forcing the address family requires HTTPClient to use an *http.Transport
+// (or the default transport); other transports are used as they are.
+func (p *Provider) UpdateDynamicRecord(ctx context.Context, zone, name string, version IPVersion) (libdns.Address, error) {
+	var network, recType string
+	switch version {
+	case IPv4:
+		network, recType = "tcp4", "A"
+	case IPv6:
+		network, recType = "tcp6", "AAAA"
+	default:
+		return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: unsupported IP version %d", version)
+	}
+
+	err := p.login(ctx)
+	if err != nil {
+		return libdns.Address{}, fmt.Errorf("login: provider login failed: %w", err)
+	}
+
+	hosted, err := p.resolveZone(ctx, zone)
+	if err != nil {
+		return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: %w", err)
+	}
+
+	host := hosted.host(normalizeName(name))
+	fqdn := hosted.Name
+	if host != "@" {
+		fqdn = host + "." + hosted.Name
+	}
+
+	client := p.clientForNetwork(network)
+	defer client.CloseIdleConnections()
+
+	unlock := p.lockZone(hosted.Name)
+	defer unlock()
+
+	_, err = p.doAPIRequestWithClient(ctx, client, "UpdateDynamicRecord", hosted.Name, "PUT", p.apiURL()+"/dynamic/"+url.PathEscape(fqdn), nil)
+	if err != nil {
+		return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: %w", err)
+	}
+
+	current, err := p.getRecordsAt(ctx, hosted, host, recType)
+	if err != nil {
+		return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: %w", err)
+	}
+
+	for _, r := range hosted.fromHosts(current) {
+		record, err := r.GetLibdnsRecord()
+		if err != nil {
+			return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: failed to parse record %s: %w", r.GetName(), err)
+		}
+		if addr, ok := record.(libdns.Address); ok {
+			return addr, nil
+		}
+	}
+	return libdns.Address{}, fmt.Errorf("UpdateDynamicRecord: no %s record for %s after update", recType, fqdn)
+}
+
+// clientForNetwork returns a copy of the HTTP client which only dials
+// connections over network, such as "tcp4".
+func (p *Provider) clientForNetwork(network string) *http.Client {
+	base := p.httpClient()
+	client := *base
+
+	var transport *http.Transport
+	switch t := base.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return &client
+	}
+
+	dialer := &net.Dialer{}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+	client.Transport = transport
+	return &client
+}
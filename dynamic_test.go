@@ -0,0 +1,32 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestUpdateDynamicRecord(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	provider.MaxRetries = -1
+
+	// The test server listens on 127.0.0.1, so the update is only possible
+	// when the request is made over IPv4.
+	addr, err := provider.UpdateDynamicRecord(context.Background(), "example.com.", "home", mythicbeasts.IPv4)
+	if err != nil {
+		t.Fatalf("UpdateDynamicRecord(IPv4): %v", err)
+	}
+	if addr.Name != "home" || addr.IP.String() != "127.0.0.1" {
+		t.Errorf("returned %+v, want home at 127.0.0.1", addr)
+	}
+
+	if _, err := provider.UpdateDynamicRecord(context.Background(), "example.com.", "home", mythicbeasts.IPv6); err == nil {
+		t.Errorf("UpdateDynamicRecord(IPv6) succeeded over an IPv4-only connection")
+	}
+}
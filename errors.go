@@ -0,0 +1,180 @@
+package mythicbeasts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// Sentinel errors matched by APIError, for use with errors.Is.
+var (
+	ErrUnauthorized = errors.New("mythicbeasts: unauthorized")
+	ErrForbidden    = errors.New("mythicbeasts: forbidden")
+	ErrZoneNotFound = errors.New("mythicbeasts: zone not found")
+	ErrRateLimited  = errors.New("mythicbeasts: rate limited")
+	ErrValidation   = errors.New("mythicbeasts: validation failed")
+)
+
+// APIError is returned when the auth or DNS API responds with an error.
+type APIError struct {
+	StatusCode int      // HTTP status of the response
+	Op         string   // Operation which made the request, such as "login" or "addRecords"
+	Zone       string   // Zone the request was for, if any
+	Messages   []string // Error messages from the response body
+}
+
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	if e.Zone != "" {
+		fmt.Fprintf(&sb, "zone %s: ", e.Zone)
+	}
+	fmt.Fprintf(&sb, "api error %d", e.StatusCode)
+	if len(e.Messages) > 0 {
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(e.Messages, "; "))
+	}
+	return sb.String()
+}
+
+// Is reports whether the status of e corresponds to the sentinel target.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrZoneNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// parseErrorMessages extracts the messages from an error response body, which
+// holds either a single error or a list of them.
+func parseErrorMessages(body []byte) []string {
+	errorsResp := mythicErrors{}
+	if json.Unmarshal(body, &errorsResp) == nil && len(errorsResp.Errors) > 0 {
+		return errorsResp.Errors
+	}
+
+	errResp := mythicError{}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		return []string{errResp.Error}
+	}
+	return nil
+}
+
+// FailedDeletion is a record which DeleteRecords matched in the zone but
+// could not delete.
+type FailedDeletion struct {
+	Record libdns.Record
+	Err    error
+}
+
+// DeleteError is returned by DeleteRecords when some of the matched records
+// could not be deleted, or when the API removed records which were not asked
+// for. The records returned alongside it were deleted.
+type DeleteError struct {
+	Failed     []FailedDeletion
+	Unexpected []libdns.Record // Records removed although they did not match
+}
+
+func (e *DeleteError) Error() string {
+	var parts []string
+	if len(e.Failed) > 0 {
+		msgs := make([]string, len(e.Failed))
+		for i, f := range e.Failed {
+			rr := recordRR(f.Record)
+			msgs[i] = fmt.Sprintf("%s %s %q: %v", rr.Name, rr.Type, rr.Data, f.Err)
+		}
+		parts = append(parts, fmt.Sprintf("failed to delete %d records: %s", len(e.Failed), strings.Join(msgs, "; ")))
+	}
+	if len(e.Unexpected) > 0 {
+		msgs := make([]string, len(e.Unexpected))
+		for i, record := range e.Unexpected {
+			rr := recordRR(record)
+			msgs[i] = fmt.Sprintf("%s %s %q", rr.Name, rr.Type, rr.Data)
+		}
+		parts = append(parts, fmt.Sprintf("unexpectedly removed %d records: %s", len(e.Unexpected), strings.Join(msgs, "; ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap returns the errors of the failed deletions.
+func (e *DeleteError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// LineError is a problem with a line of a zone file.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// ImportError is returned by ImportZone when lines of the zone file are
+// invalid or unsupported. Nothing is written to the zone in this case.
+type ImportError struct {
+	Lines []LineError
+}
+
+func (e *ImportError) Error() string {
+	msgs := make([]string, len(e.Lines))
+	for i, l := range e.Lines {
+		msgs[i] = fmt.Sprintf("line %d: %v", l.Line, l.Err)
+	}
+	return fmt.Sprintf("%d invalid lines in zone file: %s", len(e.Lines), strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrValidation.
+func (e *ImportError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// InvalidRecord is a record which failed validation. Index is its position
+// in the records passed to the Provider.
+type InvalidRecord struct {
+	Index int
+	Name  string
+	Type  string
+	Err   error
+}
+
+// ValidationError is returned by AppendRecords and SetRecords when records
+// are invalid. Nothing is sent to the API in this case.
+type ValidationError struct {
+	Records []InvalidRecord
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Records))
+	for i, r := range e.Records {
+		msgs[i] = fmt.Sprintf("record %d (%s %s): %v", r.Index, r.Name, r.Type, r.Err)
+	}
+	return fmt.Sprintf("%d invalid records: %s", len(e.Records), strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Unwrap returns the errors of the invalid records.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Records))
+	for i, r := range e.Records {
+		errs[i] = r.Err
+	}
+	return errs
+}
@@ -1,8 +1,5 @@
 module github.com/libdns/mythicbeasts
 
-go 1.16
+go 1.21
 
-require (
-	github.com/libdns/libdns v1.1.1
-	golang.org/x/net v0.0.0-20220531201128-c960675eff93
-)
+require github.com/libdns/libdns v1.1.1
@@ -0,0 +1,61 @@
+package mythicbeasts
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// log writes a message to the Provider's Logger, if it has one. Callers must
+// not pass the secret, bearer tokens or request headers.
+func (p *Provider) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if p.Logger == nil {
+		return
+	}
+	p.Logger.Log(ctx, level, msg, args...)
+}
+
+// logRequest logs an API request made with req. status is 0 if no response
+// was received, and respBody is examined for the number of records changed.
+func (p *Provider) logRequest(ctx context.Context, op, zone string, req *http.Request, status int, start time.Time, respBody []byte, err error) {
+	if p.Logger == nil {
+		return
+	}
+
+	// Only the method, path and record filters are logged. The request
+	// headers carry the bearer token, so are left out entirely.
+	args := []any{
+		slog.String("op", op),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+	}
+	if zone != "" {
+		args = append(args, slog.String("zone", zone))
+	}
+	if filters := req.URL.Query()["select"]; len(filters) > 0 {
+		args = append(args, slog.Any("select", filters))
+	}
+	if status != 0 {
+		args = append(args, slog.Int("status", status))
+	}
+	args = append(args, slog.Duration("duration", time.Since(start)))
+
+	if err != nil {
+		args = append(args, slog.Any("error", err))
+		p.log(ctx, slog.LevelWarn, "mythicbeasts: API request failed", args...)
+		return
+	}
+
+	if req.Method != "GET" {
+		update := mythicRecordUpdate{}
+		if json.Unmarshal(respBody, &update) == nil {
+			args = append(args,
+				slog.Int("records_added", update.RecordsAdded),
+				slog.Int("records_removed", update.RecordsRemoved),
+			)
+		}
+	}
+	p.log(ctx, slog.LevelDebug, "mythicbeasts: API request", args...)
+}
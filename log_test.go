@@ -0,0 +1,84 @@
+package mythicbeasts_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestLogsOmitCredentials(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300})
+
+	// Collect the credentials sent with each request: the key and secret of
+	// each login, and the bearer tokens they issue.
+	var mu sync.Mutex
+	var credentials []string
+	px := newProxy(t, srv, func(r *http.Request) {
+		_, credential, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok {
+			mu.Lock()
+			credentials = append(credentials, credential)
+			mu.Unlock()
+		}
+	})
+	defer px.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+	provider.AuthURL = px.URL + "/login"
+	provider.Logger = logger
+	ctx := context.Background()
+
+	// A login, a request whose token is rejected and replayed, and a
+	// request which fails.
+	if _, err := provider.GetRecords(ctx, "example.com."); err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	srv.RevokeTokens()
+	if _, err := provider.GetRecords(ctx, "example.com."); err != nil {
+		t.Fatalf("GetRecords after revocation: %v", err)
+	}
+	srv.FailNext(1, http.StatusBadRequest)
+	if _, err := provider.GetRecords(ctx, "example.com."); err == nil {
+		t.Fatalf("GetRecords succeeded, want an API error")
+	}
+
+	// A failed login.
+	wrong := newProvider(srv)
+	wrong.AuthURL = px.URL + "/login"
+	wrong.Secret = "wrong-secret"
+	wrong.Logger = logger
+	if _, err := wrong.GetRecords(ctx, "example.com."); err == nil {
+		t.Fatalf("GetRecords with the wrong secret succeeded")
+	}
+
+	output := logs.String()
+	for _, msg := range []string{"logged in", "token rejected", "API request failed", "login failed"} {
+		if !strings.Contains(output, msg) {
+			t.Errorf("logs do not mention %q:\n%s", msg, output)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(credentials) < 4 {
+		t.Fatalf("saw %d credentials, want logins and bearer tokens", len(credentials))
+	}
+	secrets := append([]string{srv.Secret, wrong.Secret, "Authorization", "Bearer"}, credentials...)
+	for _, secret := range secrets {
+		if strings.Contains(output, secret) {
+			t.Errorf("logs contain %q:\n%s", secret, output)
+		}
+	}
+}
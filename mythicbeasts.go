@@ -3,6 +3,8 @@ package mythicbeasts
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -24,7 +26,11 @@ type mythicAuthResponseError struct {
 type mythicRecordType interface {
 	GetName() string
 	GetType() string
+	GetData() string
 	GetLibdnsRecord() (libdns.Record, error)
+	withName(name string) mythicRecordType
+	selector() url.Values
+	validate() error
 }
 
 type mythicRecord struct {
@@ -32,6 +38,189 @@ type mythicRecord struct {
 	Name  string `json:"host,omitempty"`
 	Value string `json:"data,omitempty"`
 	TTL   int    `json:"ttl,omitempty"`
+
+	Template  bool `json:"template,omitempty"`  // The record comes from a zone template
+	Generated bool `json:"generated,omitempty"` // The record is generated by Mythic Beasts
+}
+
+// RecordSource describes where a record in a zone comes from. Records
+// returned by this package carry their RecordSource in ProviderData. DS
+// records and those of types without a mapping of their own are returned as
+// a libdns.RR, which has no such field, as are HTTPS or SVCB records which
+// libdns cannot parse; set ExcludeTemplate and ExcludeGenerated to list only
+// user records of these types.
+type RecordSource string
+
+const (
+	SourceUser      RecordSource = "user"      // Created through the API or control panel
+	SourceTemplate  RecordSource = "template"  // Provided by a zone template
+	SourceGenerated RecordSource = "generated" // Generated by Mythic Beasts
+)
+
+func (r mythicRecord) source() RecordSource {
+	switch {
+	case r.Template:
+		return SourceTemplate
+	case r.Generated:
+		return SourceGenerated
+	}
+	return SourceUser
+}
+
+// withProviderData sets the ProviderData of record to data, if its type has
+// such a field.
+func withProviderData(record libdns.Record, data interface{}) libdns.Record {
+	switch r := record.(type) {
+	case libdns.Address:
+		r.ProviderData = data
+		return r
+	case ANAME:
+		r.ProviderData = data
+		return r
+	case DNAME:
+		r.ProviderData = data
+		return r
+	case SSHFP:
+		r.ProviderData = data
+		return r
+	case TLSA:
+		r.ProviderData = data
+		return r
+	case libdns.CAA:
+		r.ProviderData = data
+		return r
+	case libdns.CNAME:
+		r.ProviderData = data
+		return r
+	case libdns.MX:
+		r.ProviderData = data
+		return r
+	case libdns.NS:
+		r.ProviderData = data
+		return r
+	case libdns.SRV:
+		r.ProviderData = data
+		return r
+	case libdns.ServiceBinding:
+		r.ProviderData = data
+		return r
+	case libdns.TXT:
+		r.ProviderData = data
+		return r
+	}
+	return record
+}
+
+// recordRR returns the generic form of record, like record.RR() but with the
+// SvcParams of a ServiceBinding in a deterministic order, so that the result
+// can be compared and written out reliably.
+func recordRR(record libdns.Record) libdns.RR {
+	rr := record.RR()
+	if sb, ok := record.(libdns.ServiceBinding); ok && rr.Data != "" {
+		rr.Name = serviceBindingName(sb, rr.Type)
+		rr.Data = fmt.Sprintf("%d %s", sb.Priority, sb.Target)
+		if sb.Priority != 0 && len(sb.Params) > 0 {
+			rr.Data += " " + encodeSvcParams(sb.Params)
+		}
+	}
+	return rr
+}
+
+// serviceBindingName returns the owner name of an HTTPS or SVCB record as
+// given by RFC 9460, such as "_8443._https.api" or "_dns" at the apex.
+// libdns leaves out the "_https" label of HTTPS records on a non-default
+// port, and appends "@" to the labels of records at the apex.
+func serviceBindingName(sb libdns.ServiceBinding, recType string) string {
+	port := sb.URLSchemePort
+	if recType == "HTTPS" && (port == 443 || port == 80) {
+		port = 0
+	}
+
+	var labels []string
+	if port != 0 {
+		labels = append(labels, fmt.Sprintf("_%d", port))
+	}
+	if recType == "SVCB" {
+		labels = append(labels, "_"+sb.Scheme)
+	} else if port != 0 {
+		labels = append(labels, "_https")
+	}
+	if sb.Name != "" && sb.Name != "@" {
+		labels = append(labels, sb.Name)
+	}
+	if len(labels) == 0 {
+		return "@"
+	}
+	return strings.Join(labels, ".")
+}
+
+// svcParamKeys are the numbers of the SvcParamKeys registered with IANA,
+// which determine the order in which they are written.
+var svcParamKeys = map[string]int{
+	"mandatory":       0,
+	"alpn":            1,
+	"no-default-alpn": 2,
+	"port":            3,
+	"ipv4hint":        4,
+	"ech":             5,
+	"ipv6hint":        6,
+	"dohpath":         7,
+	"ohttp":           8,
+}
+
+// svcParamKeyNumber returns the number of a SvcParamKey, either registered
+// or in the generic form "keyNNNNN", or -1 if it is neither.
+func svcParamKeyNumber(key string) int {
+	if n, ok := svcParamKeys[key]; ok {
+		return n
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(key, "key"), 10, 16); strings.HasPrefix(key, "key") && err == nil {
+		return int(n)
+	}
+	return -1
+}
+
+// encodeSvcParams renders params in presentation format (RFC 9460 section
+// 2.1), ordered by key number as on the wire. Unlike SvcParams.String, the
+// output is the same each time.
+func encodeSvcParams(params libdns.SvcParams) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ni, nj := svcParamKeyNumber(keys[i]), svcParamKeyNumber(keys[j])
+		if ni != nj && ni >= 0 && nj >= 0 {
+			return ni < nj
+		}
+		if (ni >= 0) != (nj >= 0) {
+			return ni >= 0
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := params[key]
+		escaped := make([]string, len(values))
+		quote := false
+		for i, value := range values {
+			quote = quote || strings.ContainsAny(value, "\" \t")
+			value = strings.ReplaceAll(value, `"`, `\"`)
+			escaped[i] = strings.ReplaceAll(value, ",", `\,`)
+		}
+
+		value := strings.Join(escaped, ",")
+		switch {
+		case value == "" && len(values) <= 1:
+			parts = append(parts, key)
+		case quote:
+			parts = append(parts, key+`="`+value+`"`)
+		default:
+			parts = append(parts, key+"="+value)
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 func (r mythicRecord) GetName() string {
@@ -40,13 +229,31 @@ func (r mythicRecord) GetName() string {
 func (r mythicRecord) GetType() string {
 	return r.Type
 }
+func (r mythicRecord) GetData() string {
+	return r.Value
+}
+func (r mythicRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+
+// selector returns the select option fields which identify the record among
+// those at its host, that is its type, data and any type-specific fields.
+func (r mythicRecord) selector() url.Values {
+	return url.Values{"type": {r.Type}, "data": {r.Value}}
+}
+
 func (r mythicRecord) GetLibdnsRecord() (libdns.Record, error) {
-	return libdns.RR{
+	record, err := parseRR(libdns.RR{
 		Type: r.Type,
 		Name: r.Name,
 		Data: r.Value,
 		TTL:  time.Duration(r.TTL) * time.Second,
-	}.Parse()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withProviderData(record, r.source()), nil
 }
 
 type mythicMxRecord struct {
@@ -60,12 +267,23 @@ func (r mythicMxRecord) GetName() string {
 func (r mythicMxRecord) GetType() string {
 	return r.Type
 }
+func (r mythicMxRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicMxRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("mx_priority", strconv.Itoa(int(r.Priority)))
+	return values
+}
 func (r mythicMxRecord) GetLibdnsRecord() (libdns.Record, error) {
 	return libdns.MX{
 		Name:       r.Name,
 		TTL:        time.Duration(r.TTL) * time.Second,
 		Preference: r.Priority,
 		Target:     r.Value,
+
+		ProviderData: r.source(),
 	}, nil
 }
 
@@ -81,6 +299,16 @@ func (r mythicCaaRecord) GetName() string {
 func (r mythicCaaRecord) GetType() string {
 	return r.Type
 }
+func (r mythicCaaRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicCaaRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("caa_flags", strconv.Itoa(int(r.Flags)))
+	values.Set("caa_tag", r.Tag)
+	return values
+}
 func (r mythicCaaRecord) GetLibdnsRecord() (libdns.Record, error) {
 	return libdns.CAA{
 		Name:  r.Name,
@@ -88,6 +316,8 @@ func (r mythicCaaRecord) GetLibdnsRecord() (libdns.Record, error) {
 		Flags: r.Flags,
 		Tag:   r.Tag,
 		Value: r.Value,
+
+		ProviderData: r.source(),
 	}, nil
 }
 
@@ -104,17 +334,37 @@ func (r mythicSrvRecord) GetName() string {
 func (r mythicSrvRecord) GetType() string {
 	return r.Type
 }
+func (r mythicSrvRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicSrvRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("srv_priority", strconv.Itoa(int(r.Priority)))
+	values.Set("srv_weight", strconv.Itoa(int(r.Weight)))
+	values.Set("srv_port", strconv.Itoa(int(r.Port)))
+	return values
+}
 func (r mythicSrvRecord) GetLibdnsRecord() (libdns.Record, error) {
-	nameParts := strings.Split(r.Name, ".")
+	nameParts := strings.SplitN(r.Name, ".", 3)
+	if len(nameParts) < 2 {
+		return nil, fmt.Errorf("SRV host %s is not in the form _service._proto[.name]", r.Name)
+	}
+	name := "@"
+	if len(nameParts) == 3 {
+		name = nameParts[2]
+	}
 	return libdns.SRV{
 		Service:   strings.TrimPrefix(nameParts[0], "_"),
 		Transport: strings.TrimPrefix(nameParts[1], "_"),
-		Name:      nameParts[2],
+		Name:      name,
 		TTL:       time.Duration(r.TTL) * time.Second,
 		Priority:  r.Priority,
 		Weight:    r.Weight,
 		Port:      r.Port,
 		Target:    r.Value,
+
+		ProviderData: r.source(),
 	}, nil
 }
 
@@ -130,12 +380,25 @@ func (r mythicSshfpRecord) GetName() string {
 func (r mythicSshfpRecord) GetType() string {
 	return r.Type
 }
+func (r mythicSshfpRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicSshfpRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("sshfp_algorithm", strconv.Itoa(int(r.Algorithm)))
+	values.Set("sshfp_type", strconv.Itoa(int(r.SshfpType)))
+	return values
+}
 func (r mythicSshfpRecord) GetLibdnsRecord() (libdns.Record, error) {
-	return libdns.RR{
-		Name: r.Name,
-		TTL:  time.Duration(r.TTL) * time.Second,
-		Type: "SSHFP",
-		Data: fmt.Sprintf("%d %d %s", r.Algorithm, r.SshfpType, r.Value),
+	return SSHFP{
+		Name:            r.Name,
+		TTL:             time.Duration(r.TTL) * time.Second,
+		Algorithm:       r.Algorithm,
+		FingerprintType: r.SshfpType,
+		Fingerprint:     r.Value,
+
+		ProviderData: r.source(),
 	}, nil
 }
 
@@ -152,15 +415,125 @@ func (r mythicTlsaRecord) GetName() string {
 func (r mythicTlsaRecord) GetType() string {
 	return r.Type
 }
+func (r mythicTlsaRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicTlsaRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("tlsa_usage", strconv.Itoa(int(r.Usage)))
+	values.Set("tlsa_selector", strconv.Itoa(int(r.Selector)))
+	values.Set("tlsa_matching", strconv.Itoa(int(r.Matching)))
+	return values
+}
 func (r mythicTlsaRecord) GetLibdnsRecord() (libdns.Record, error) {
+	return TLSA{
+		Name:            r.Name,
+		TTL:             time.Duration(r.TTL) * time.Second,
+		Usage:           r.Usage,
+		Selector:        r.Selector,
+		MatchingType:    r.Matching,
+		AssociationData: r.Value,
+
+		ProviderData: r.source(),
+	}, nil
+}
+
+type mythicDsRecord struct {
+	mythicRecord
+	KeyTag     uint16 `json:"ds_key_tag,omitempty"`
+	Algorithm  uint8  `json:"ds_algorithm,omitempty"`
+	DigestType uint8  `json:"ds_digest_type,omitempty"`
+}
+
+func (r mythicDsRecord) GetName() string {
+	return r.Name
+}
+func (r mythicDsRecord) GetType() string {
+	return r.Type
+}
+func (r mythicDsRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicDsRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("ds_key_tag", strconv.Itoa(int(r.KeyTag)))
+	values.Set("ds_algorithm", strconv.Itoa(int(r.Algorithm)))
+	values.Set("ds_digest_type", strconv.Itoa(int(r.DigestType)))
+	return values
+}
+func (r mythicDsRecord) GetLibdnsRecord() (libdns.Record, error) {
 	return libdns.RR{
 		Name: r.Name,
 		TTL:  time.Duration(r.TTL) * time.Second,
-		Type: "TLSA",
-		Data: fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.Matching, r.Value),
+		Type: "DS",
+		Data: fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, r.Value),
 	}, nil
 }
 
+// mythicSvcbRecord is an HTTPS or SVCB record. The data holds the target,
+// and the SvcParams are given in presentation format.
+type mythicSvcbRecord struct {
+	mythicRecord
+	Priority uint16 `json:"svcb_priority,omitempty"`
+	Params   string `json:"svcb_params,omitempty"`
+}
+
+func (r mythicSvcbRecord) GetName() string {
+	return r.Name
+}
+func (r mythicSvcbRecord) GetType() string {
+	return r.Type
+}
+func (r mythicSvcbRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicSvcbRecord) selector() url.Values {
+	values := r.mythicRecord.selector()
+	values.Set("svcb_priority", strconv.Itoa(int(r.Priority)))
+	values.Set("svcb_params", r.Params)
+	return values
+}
+func (r mythicSvcbRecord) GetLibdnsRecord() (libdns.Record, error) {
+	rr := libdns.RR{
+		Name: r.Name,
+		TTL:  time.Duration(r.TTL) * time.Second,
+		Type: r.Type,
+		Data: strings.TrimSpace(fmt.Sprintf("%d %s %s", r.Priority, r.Value, r.Params)),
+	}
+	record, err := rr.Parse()
+	if err != nil {
+		// Names which libdns cannot map to a scheme are still listed.
+		return rr, nil
+	}
+	return withProviderData(record, r.source()), nil
+}
+
+// mythicGenericRecord is a record of a type without a mapping of its own.
+// It is represented as a libdns.RR with the record's data as given by the
+// API, without parsing, and converted back from one by FromLibdns.
+type mythicGenericRecord struct {
+	mythicRecord
+}
+
+func (r mythicGenericRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r mythicGenericRecord) GetLibdnsRecord() (libdns.Record, error) {
+	return libdns.RR{
+		Name: r.Name,
+		TTL:  time.Duration(r.TTL) * time.Second,
+		Type: r.Type,
+		Data: r.Value,
+	}, nil
+}
+func (r mythicGenericRecord) validate() error {
+	return r.validateCommon()
+}
+
 type mythicRecords struct {
 	Records []mythicRecordType `json:"records,omitempty"`
 }
@@ -187,137 +560,209 @@ func (mrl *mythicRecords) UnmarshalJSON(data []byte) error {
 		switch base.Type {
 		case "A", "AAAA", "CNAME", "NS", "PTR", "TXT":
 			mrl.Records[r] = base
-		case "ANAME", "DNAME": // Unoffical types
+		case "ANAME", "DNAME": // Returned as this package's ANAME and DNAME
 			mrl.Records[r] = base
 		case "MX":
 			var mxRecord mythicMxRecord
 			if err := json.Unmarshal(rawRecord, &mxRecord); err != nil {
-				return fmt.Errorf("failed to unmarshal MX record: %v", err)
+				return fmt.Errorf("failed to unmarshal MX record: %w", err)
 			}
 			mrl.Records[r] = mxRecord
 		case "CAA":
 			var caaRecord mythicCaaRecord
 			if err := json.Unmarshal(rawRecord, &caaRecord); err != nil {
-				return fmt.Errorf("failed to unmarshal CAA record: %v", err)
+				return fmt.Errorf("failed to unmarshal CAA record: %w", err)
 			}
 			mrl.Records[r] = caaRecord
 		case "SRV":
 			var srvRecord mythicSrvRecord
 			if err := json.Unmarshal(rawRecord, &srvRecord); err != nil {
-				return fmt.Errorf("failed to unmarshal SRV record: %v", err)
+				return fmt.Errorf("failed to unmarshal SRV record: %w", err)
 			}
 			mrl.Records[r] = srvRecord
 		case "SSHFP":
 			var sshfpRecord mythicSshfpRecord
 			if err := json.Unmarshal(rawRecord, &sshfpRecord); err != nil {
-				return fmt.Errorf("failed to unmarshal SSHFP record: %v", err)
+				return fmt.Errorf("failed to unmarshal SSHFP record: %w", err)
 			}
 			mrl.Records[r] = sshfpRecord
 		case "TLSA":
 			var tlsaRecord mythicTlsaRecord
 			if err := json.Unmarshal(rawRecord, &tlsaRecord); err != nil {
-				return fmt.Errorf("failed to unmarshal TLSA record: %v", err)
+				return fmt.Errorf("failed to unmarshal TLSA record: %w", err)
 			}
 			mrl.Records[r] = tlsaRecord
+		case "HTTPS", "SVCB":
+			var svcbRecord mythicSvcbRecord
+			if err := json.Unmarshal(rawRecord, &svcbRecord); err != nil {
+				return fmt.Errorf("failed to unmarshal %s record: %w", base.Type, err)
+			}
+			mrl.Records[r] = svcbRecord
+		case "DS":
+			var dsRecord mythicDsRecord
+			if err := json.Unmarshal(rawRecord, &dsRecord); err != nil {
+				return fmt.Errorf("failed to unmarshal DS record: %w", err)
+			}
+			mrl.Records[r] = dsRecord
 		default:
-			return fmt.Errorf("unknown type: %s", base.Type)
+			// Types this package does not know, which may be new to the
+			// API, are passed through as generic records.
+			mrl.Records[r] = mythicGenericRecord{mythicRecord: base}
 		}
 	}
 
 	return nil
 }
+
+// FromLibdns converts records for the API and validates them; see Validate.
+// Records which cannot be converted are reported by Validate along with the
+// rest, by their index in records.
 func (mrl *mythicRecords) FromLibdns(libdnsrecords []libdns.Record) error {
 	for _, record := range libdnsrecords {
-		var rr = record.RR()
-
-		var mr mythicRecord
-		mr.Type = rr.Type
-		mr.Name = rr.Name
-		mr.Value = rr.Data
-		mr.TTL = int(rr.TTL.Seconds())
-
-		switch r := record.(type) {
-		case libdns.Address, libdns.CNAME, libdns.NS, libdns.TXT:
-			mrl.Records = append(mrl.Records, mr)
-		case libdns.RR:
-			if rr.Type == "SSHFP" {
-				valueParts := strings.Split(rr.Data, " ")
-				algorithm, err := strconv.ParseUint(valueParts[0], 10, 8)
-				if err != nil {
-					return fmt.Errorf("FromLibdns: failed to parse SSHFP algorithm: %w", err)
-				}
-				sshfptype, err := strconv.ParseUint(valueParts[1], 10, 8)
-				if err != nil {
-					return fmt.Errorf("FromLibdns: failed to parse SSHFP type: %w", err)
-				}
-				sshfp := mythicSshfpRecord{
-					mythicRecord: mr,
-					Algorithm:    uint8(algorithm),
-					SshfpType:    uint8(sshfptype),
-				}
-				sshfp.Value = valueParts[2]
-				mrl.Records = append(mrl.Records, sshfp)
-				continue
-			} else if rr.Type == "TLSA" {
-				valueParts := strings.Split(rr.Data, " ")
-				usage, err := strconv.ParseUint(valueParts[0], 10, 8)
-				if err != nil {
-					return fmt.Errorf("FromLibdns: failed to parse TLSA usage: %w", err)
-				}
-				selector, err := strconv.ParseUint(valueParts[1], 10, 8)
-				if err != nil {
-					return fmt.Errorf("FromLibdns: failed to parse TLSA selector: %w", err)
-				}
-				matching, err := strconv.ParseUint(valueParts[2], 10, 8)
-				if err != nil {
-					return fmt.Errorf("FromLibdns: failed to parse TLSA matching: %w", err)
-				}
-				tlsa := mythicTlsaRecord{
-					mythicRecord: mr,
-					Usage:        uint8(usage),
-					Selector:     uint8(selector),
-					Matching:     uint8(matching),
-				}
-				tlsa.Value = valueParts[3]
-				mrl.Records = append(mrl.Records, tlsa)
-				continue
-			} else {
-				mrl.Records = append(mrl.Records, mr)
+		mr, err := fromLibdns(record)
+		if err != nil {
+			rr := record.RR()
+			mr = invalidRecord{mythicRecord: mythicRecord{Type: rr.Type, Name: rr.Name, Value: rr.Data}, err: err}
+		}
+		mrl.Records = append(mrl.Records, mr)
+	}
+
+	return mrl.Validate()
+}
+
+// fromLibdns converts a single record for the API.
+func fromLibdns(record libdns.Record) (mythicRecordType, error) {
+	var rr = recordRR(record)
+
+	var mr mythicRecord
+	mr.Type = rr.Type
+	// The API requires a host, so the apex is given as "@" as usual.
+	mr.Name = normalizeName(rr.Name)
+	mr.Value = rr.Data
+	mr.TTL = int(rr.TTL.Seconds())
+
+	switch r := record.(type) {
+	case libdns.Address, libdns.CNAME, libdns.NS, libdns.TXT, ANAME, DNAME:
+		return mr, nil
+	case SSHFP, TLSA:
+		return fromLibdns(rr)
+	case libdns.RR:
+		if rr.Type == "SSHFP" {
+			fields, value, err := splitNumericFields(rr.Data, "algorithm", "type")
+			if err != nil {
+				return nil, fmt.Errorf("SSHFP %w", err)
 			}
-		case libdns.MX:
-			var mxr mythicMxRecord
-			mxr = mythicMxRecord{
+			sshfp := mythicSshfpRecord{
 				mythicRecord: mr,
-				Priority:     r.Preference,
+				Algorithm:    fields[0],
+				SshfpType:    fields[1],
 			}
-			mxr.Value = r.Target
-			mrl.Records = append(mrl.Records, mxr)
-		case libdns.CAA:
-			var caar mythicCaaRecord
-			caar = mythicCaaRecord{
+			sshfp.Value = value
+			return sshfp, nil
+		} else if rr.Type == "TLSA" {
+			fields, value, err := splitNumericFields(rr.Data, "usage", "selector", "matching type")
+			if err != nil {
+				return nil, fmt.Errorf("TLSA %w", err)
+			}
+			tlsa := mythicTlsaRecord{
 				mythicRecord: mr,
-				Flags:        r.Flags,
-				Tag:          r.Tag,
+				Usage:        fields[0],
+				Selector:     fields[1],
+				Matching:     fields[2],
+			}
+			tlsa.Value = value
+			return tlsa, nil
+		} else if rr.Type == "DS" {
+			keyTag, rest, _ := strings.Cut(strings.TrimSpace(rr.Data), " ")
+			tag, err := strconv.ParseUint(keyTag, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("DS key tag %q is not a number from 0 to 65535", keyTag)
 			}
-			caar.Value = r.Value
-			mrl.Records = append(mrl.Records, caar)
-		case libdns.SRV:
-			var srvr mythicSrvRecord
-			srvr = mythicSrvRecord{
+			fields, value, err := splitNumericFields(rest, "algorithm", "digest type")
+			if err != nil {
+				return nil, fmt.Errorf("DS %w", err)
+			}
+			ds := mythicDsRecord{
 				mythicRecord: mr,
-				Priority:     r.Priority,
-				Weight:       r.Weight,
-				Port:         r.Port,
+				KeyTag:       uint16(tag),
+				Algorithm:    fields[0],
+				DigestType:   fields[1],
 			}
-			srvr.Name = fmt.Sprintf("_%s._%s.%s", r.Service, r.Transport, r.Name)
-			srvr.Value = r.Target
-			mrl.Records = append(mrl.Records, srvr)
-		default:
-			return fmt.Errorf("FromLibdns: unknown record type %T", r)
+			ds.Value = value
+			return ds, nil
+		}
+		// Types with a record struct of their own, such as MX or HTTPS,
+		// are parsed so that their data is split into the API's fields
+		// and checked, unless parsing changes the type, as for an A record
+		// with IPv6 data, which validate reports. Other types, including
+		// those read as generic records, are sent with their data as is.
+		parsed, err := parseRR(rr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := parsed.(libdns.RR); !ok && parsed.RR().Type == rr.Type {
+			return fromLibdns(parsed)
+		}
+		return mr, nil
+	case libdns.MX:
+		var mxr mythicMxRecord
+		mxr = mythicMxRecord{
+			mythicRecord: mr,
+			Priority:     r.Preference,
+		}
+		mxr.Value = r.Target
+		return mxr, nil
+	case libdns.ServiceBinding:
+		svcb := mythicSvcbRecord{
+			mythicRecord: mr,
+			Priority:     r.Priority,
+		}
+		svcb.Value = r.Target
+		// SvcParams are not allowed in AliasMode, which validate reports.
+		svcb.Params = encodeSvcParams(r.Params)
+		return svcb, nil
+	case libdns.CAA:
+		var caar mythicCaaRecord
+		caar = mythicCaaRecord{
+			mythicRecord: mr,
+			Flags:        r.Flags,
+			Tag:          r.Tag,
+		}
+		caar.Value = r.Value
+		return caar, nil
+	case libdns.SRV:
+		var srvr mythicSrvRecord
+		srvr = mythicSrvRecord{
+			mythicRecord: mr,
+			Priority:     r.Priority,
+			Weight:       r.Weight,
+			Port:         r.Port,
 		}
+		srvr.Value = r.Target
+		return srvr, nil
+	default:
+		return nil, fmt.Errorf("unknown record type %T", r)
 	}
+}
 
-	return nil
+// splitNumericFields splits data into the leading 8-bit numeric fields named
+// by names and the remaining hex value, which may contain spaces. The value
+// is returned without spaces and in lower case.
+func splitNumericFields(data string, names ...string) ([]uint8, string, error) {
+	parts := strings.Fields(data)
+	if len(parts) <= len(names) {
+		return nil, "", fmt.Errorf("data %q needs %d numeric fields followed by a hex value", data, len(names))
+	}
+
+	fields := make([]uint8, len(names))
+	for i, name := range names {
+		v, err := strconv.ParseUint(parts[i], 10, 8)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s %q is not a number from 0 to 255", name, parts[i])
+		}
+		fields[i] = uint8(v)
+	}
+	return fields, strings.ToLower(strings.Join(parts[len(names):], "")), nil
 }
 
 type mythicRecordUpdate struct {
@@ -330,6 +775,10 @@ type mythicError struct {
 	Error string `json:"error,omitempty"`
 }
 
+type mythicZones struct {
+	Zones []string `json:"zones"`
+}
+
 type mythicErrors struct {
 	Errors []string `json:",omitempty"`
 }
@@ -0,0 +1,295 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestUnknownRecordTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		record mythicbeaststest.Record
+		want   libdns.RR
+	}{
+		{
+			name:   "LOC",
+			record: mythicbeaststest.Record{Host: "office", Type: "LOC", Data: "51 30 12.748 N 0 7 39.611 W 0m", TTL: 300},
+			want:   libdns.RR{Name: "office", Type: "LOC", Data: "51 30 12.748 N 0 7 39.611 W 0m", TTL: 300 * time.Second},
+		},
+		{
+			name:   "NAPTR",
+			record: mythicbeaststest.Record{Host: "@", Type: "NAPTR", Data: `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`, TTL: 600},
+			want:   libdns.RR{Name: "@", Type: "NAPTR", Data: `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`, TTL: 600 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com",
+				mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+				tt.record,
+			)
+
+			provider := newProvider(srv)
+			records, err := provider.GetRecords(context.Background(), "example.com.")
+			if err != nil {
+				t.Fatalf("GetRecords: %v", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("listed %d records, want 2", len(records))
+			}
+			if rr, ok := records[1].(libdns.RR); !ok || rr != tt.want {
+				t.Errorf("listed %#v, want %#v", records[1], tt.want)
+			}
+
+			// Records listed this way can be given back to delete them.
+			deleted, err := provider.DeleteRecords(context.Background(), "example.com.", records[1:])
+			if err != nil || len(deleted) != 1 {
+				t.Errorf("DeleteRecords returned %d records, %v; want 1", len(deleted), err)
+			}
+			if remaining := srv.Records("example.com"); len(remaining) != 1 || remaining[0].Type != "A" {
+				t.Errorf("zone holds %+v, want only the A record", remaining)
+			}
+		})
+	}
+}
+
+func TestUnsupportedTypeRejectedByAPI(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	_, err := newProvider(srv).AppendRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.RR{Name: "office", Type: "LOC", Data: "51 30 12.748 N 0 7 39.611 W 0m"},
+	})
+	var apiErr *mythicbeasts.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, mythicbeasts.ErrValidation) {
+		t.Errorf("error %v, want a validation *APIError", err)
+	}
+}
+
+func TestDSRecords(t *testing.T) {
+	digest := strings.Repeat("cd", 32)
+
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+	provider := newProvider(srv)
+	ctx := context.Background()
+
+	// Two DS records differing only in key tag, as during a key rollover.
+	ds := []libdns.Record{
+		libdns.RR{Name: "child", Type: "DS", TTL: 3600 * time.Second, Data: "12345 13 2 " + digest},
+		libdns.RR{Name: "child", Type: "DS", TTL: 3600 * time.Second, Data: "54321 13 2 " + digest},
+	}
+	added, err := provider.AppendRecords(ctx, "example.com.", ds)
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if !reflect.DeepEqual(added, ds) {
+		t.Errorf("added %#v, want %#v", added, ds)
+	}
+
+	want := mythicbeaststest.Record{Host: "child", Type: "DS", Data: digest, TTL: 3600, DSKeyTag: 12345, DSAlgorithm: 13, DSDigestType: 2}
+	if records := srv.Records("example.com"); len(records) != 2 || records[0] != want {
+		t.Errorf("zone holds %+v, want %+v first", records, want)
+	}
+
+	deleted, err := provider.DeleteRecords(ctx, "example.com.", ds[:1])
+	if err != nil || len(deleted) != 1 {
+		t.Fatalf("DeleteRecords returned %d records, %v; want 1", len(deleted), err)
+	}
+	listed, err := provider.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	if !reflect.DeepEqual(listed, ds[1:]) {
+		t.Errorf("listed %#v after deleting the first, want %#v", listed, ds[1:])
+	}
+}
+
+func TestServiceBindingRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		record libdns.ServiceBinding
+		stored mythicbeaststest.Record
+	}{
+		{
+			name: "HTTPS ServiceMode",
+			record: libdns.ServiceBinding{
+				Scheme: "https", Name: "@", TTL: 300 * time.Second, Priority: 1, Target: ".",
+				Params: libdns.SvcParams{"alpn": {"h2", "h3"}, "port": {"8443"}},
+			},
+			stored: mythicbeaststest.Record{Host: "@", Type: "HTTPS", Data: ".", TTL: 300, SVCBPriority: 1, SVCBParams: "alpn=h2,h3 port=8443"},
+		},
+		{
+			name: "HTTPS AliasMode",
+			record: libdns.ServiceBinding{
+				Scheme: "https", Name: "www", TTL: 300 * time.Second, Priority: 0, Target: "cdn.example.net.",
+				Params: libdns.SvcParams{},
+			},
+			stored: mythicbeaststest.Record{Host: "www", Type: "HTTPS", Data: "cdn.example.net.", TTL: 300},
+		},
+		{
+			name: "HTTPS on another port",
+			record: libdns.ServiceBinding{
+				Scheme: "https", URLSchemePort: 8443, Name: "api", TTL: 300 * time.Second, Priority: 1, Target: "api.example.net.",
+				Params: libdns.SvcParams{"ipv4hint": {"192.0.2.1"}},
+			},
+			stored: mythicbeaststest.Record{Host: "_8443._https.api", Type: "HTTPS", Data: "api.example.net.", TTL: 300, SVCBPriority: 1, SVCBParams: "ipv4hint=192.0.2.1"},
+		},
+		{
+			name: "SVCB at the apex",
+			record: libdns.ServiceBinding{
+				Scheme: "dns", Name: "@", TTL: 300 * time.Second, Priority: 1, Target: "dns.example.net.",
+				Params: libdns.SvcParams{"alpn": {"dot"}},
+			},
+			stored: mythicbeaststest.Record{Host: "_dns", Type: "SVCB", Data: "dns.example.net.", TTL: 300, SVCBPriority: 1, SVCBParams: "alpn=dot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+			provider := newProvider(srv)
+
+			added, err := provider.AppendRecords(context.Background(), "example.com.", []libdns.Record{tt.record})
+			if err != nil {
+				t.Fatalf("AppendRecords: %v", err)
+			}
+			if records := srv.Records("example.com"); len(records) != 1 || records[0] != tt.stored {
+				t.Errorf("zone holds %+v, want %+v", records, tt.stored)
+			}
+
+			want := tt.record
+			want.ProviderData = mythicbeasts.SourceUser
+			if len(added) != 1 || !reflect.DeepEqual(added[0], want) {
+				t.Errorf("added %#v, want %#v", added, want)
+			}
+		})
+	}
+}
+
+func TestServiceBindingFallback(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	// libdns cannot name the scheme of an SVCB record without one in its
+	// owner name, so it is listed as a generic record.
+	srv.AddZone("example.com", mythicbeaststest.Record{Host: "plain", Type: "SVCB", Data: ".", TTL: 300, SVCBPriority: 1, SVCBParams: "alpn=h2"})
+
+	records, err := newProvider(srv).GetRecords(context.Background(), "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	want := libdns.RR{Name: "plain", Type: "SVCB", TTL: 300 * time.Second, Data: "1 . alpn=h2"}
+	if len(records) != 1 || records[0] != want {
+		t.Errorf("listed %#v, want %#v", records, want)
+	}
+}
+
+func TestAliasRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		record libdns.Record
+		want   libdns.Record
+		stored mythicbeaststest.Record
+	}{
+		{
+			name:   "ANAME at the apex",
+			record: mythicbeasts.ANAME{Name: "", TTL: 300 * time.Second, Target: "lb.example.net"},
+			want:   mythicbeasts.ANAME{Name: "@", TTL: 300 * time.Second, Target: "lb.example.net.", ProviderData: mythicbeasts.SourceUser},
+			stored: mythicbeaststest.Record{Host: "@", Type: "ANAME", Data: "lb.example.net.", TTL: 300},
+		},
+		{
+			name:   "DNAME",
+			record: mythicbeasts.DNAME{Name: "old", TTL: 600 * time.Second, Target: "new.example.net."},
+			want:   mythicbeasts.DNAME{Name: "old", TTL: 600 * time.Second, Target: "new.example.net.", ProviderData: mythicbeasts.SourceUser},
+			stored: mythicbeaststest.Record{Host: "old", Type: "DNAME", Data: "new.example.net.", TTL: 600},
+		},
+		{
+			name:   "ANAME as a generic record",
+			record: libdns.RR{Name: "www", Type: "ANAME", Data: "lb.example.net."},
+			want:   mythicbeasts.ANAME{Name: "www", TTL: 300 * time.Second, Target: "lb.example.net.", ProviderData: mythicbeasts.SourceUser},
+			stored: mythicbeaststest.Record{Host: "www", Type: "ANAME", Data: "lb.example.net.", TTL: 300},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+			provider := newProvider(srv)
+			ctx := context.Background()
+
+			added, err := provider.AppendRecords(ctx, "example.com.", []libdns.Record{tt.record})
+			if err != nil {
+				t.Fatalf("AppendRecords: %v", err)
+			}
+			if len(added) != 1 || added[0] != tt.want {
+				t.Errorf("added %#v, want %#v", added, tt.want)
+			}
+			if records := srv.Records("example.com"); len(records) != 1 || records[0] != tt.stored {
+				t.Errorf("zone holds %+v, want %+v", records, tt.stored)
+			}
+
+			deleted, err := provider.DeleteRecords(ctx, "example.com.", added)
+			if err != nil || len(deleted) != 1 {
+				t.Errorf("DeleteRecords returned %d records, %v; want 1", len(deleted), err)
+			}
+			if records := srv.Records("example.com"); len(records) != 0 {
+				t.Errorf("zone holds %+v after deleting, want nothing", records)
+			}
+		})
+	}
+}
+
+func TestSSHFPAndTLSARecords(t *testing.T) {
+	fingerprint := strings.Repeat("ab", 32)
+	digest := strings.Repeat("cd", 32)
+
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com",
+		mythicbeaststest.Record{Host: "host", Type: "SSHFP", Data: fingerprint, TTL: 300, SSHFPAlgorithm: 4, SSHFPType: 2, Template: true},
+	)
+	provider := newProvider(srv)
+	ctx := context.Background()
+
+	tlsa := mythicbeasts.TLSA{Name: "_443._tcp", TTL: 600 * time.Second, Usage: 3, Selector: 1, MatchingType: 1, AssociationData: digest}
+	if _, err := provider.AppendRecords(ctx, "example.com.", []libdns.Record{tlsa}); err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+
+	records, err := provider.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	want := []libdns.Record{
+		mythicbeasts.SSHFP{Name: "host", TTL: 300 * time.Second, Algorithm: 4, FingerprintType: 2, Fingerprint: fingerprint, ProviderData: mythicbeasts.SourceTemplate},
+		mythicbeasts.TLSA{Name: "_443._tcp", TTL: 600 * time.Second, Usage: 3, Selector: 1, MatchingType: 1, AssociationData: digest, ProviderData: mythicbeasts.SourceUser},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("GetRecords returned %#v, want %#v", records, want)
+	}
+
+	deleted, err := provider.DeleteRecords(ctx, "example.com.", records[1:])
+	if err != nil || len(deleted) != 1 {
+		t.Errorf("DeleteRecords returned %d records, %v; want 1", len(deleted), err)
+	}
+	if remaining := srv.Records("example.com"); len(remaining) != 1 || remaining[0].Type != "SSHFP" {
+		t.Errorf("zone holds %+v, want only the SSHFP record", remaining)
+	}
+}
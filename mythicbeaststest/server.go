@@ -0,0 +1,641 @@
+// Package mythicbeaststest provides an in-memory stand-in for the Mythic
+// Beasts auth and DNS v2 APIs, so that the mythicbeasts provider and its
+// consumers can be tested without live credentials.
+//
+// A typical test points a provider at a new server:
+//
+//	srv := mythicbeaststest.NewServer()
+//	defer srv.Close()
+//	srv.AddZone("example.com", mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300})
+//
+//	provider := mythicbeasts.Provider{
+//		KeyID:   srv.KeyID,
+//		Secret:  srv.Secret,
+//		APIURL:  srv.APIURL(),
+//		AuthURL: srv.AuthURL(),
+//	}
+package mythicbeaststest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials accepted by a Server returned from NewServer.
+const (
+	DefaultKeyID  = "test-key"
+	DefaultSecret = "test-secret"
+)
+
+// DefaultTTL is applied to records created without a TTL.
+const DefaultTTL = 300
+
+// Record is a DNS record as represented by the DNS v2 API. Type-specific
+// fields are only set for records of that type.
+type Record struct {
+	Host string `json:"host"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+	TTL  int    `json:"ttl"`
+
+	MXPriority     uint16 `json:"mx_priority,omitempty"`
+	SRVPriority    uint16 `json:"srv_priority,omitempty"`
+	SRVWeight      uint16 `json:"srv_weight,omitempty"`
+	SRVPort        uint16 `json:"srv_port,omitempty"`
+	CAAFlags       uint8  `json:"caa_flags,omitempty"`
+	CAATag         string `json:"caa_tag,omitempty"`
+	SSHFPAlgorithm uint8  `json:"sshfp_algorithm,omitempty"`
+	SSHFPType      uint8  `json:"sshfp_type,omitempty"`
+	TLSAUsage      uint8  `json:"tlsa_usage,omitempty"`
+	TLSASelector   uint8  `json:"tlsa_selector,omitempty"`
+	TLSAMatching   uint8  `json:"tlsa_matching,omitempty"`
+	DSKeyTag       uint16 `json:"ds_key_tag,omitempty"`
+	DSAlgorithm    uint8  `json:"ds_algorithm,omitempty"`
+	DSDigestType   uint8  `json:"ds_digest_type,omitempty"`
+	SVCBPriority   uint16 `json:"svcb_priority,omitempty"`
+	SVCBParams     string `json:"svcb_params,omitempty"`
+
+	// Template and Generated mark records which come from a zone template or
+	// are generated by the service. They are excluded by the API's
+	// exclude-template and exclude-generated options.
+	Template  bool `json:"template,omitempty"`
+	Generated bool `json:"generated,omitempty"`
+}
+
+// sameAs reports whether r and o are the same record, disregarding TTL.
+func (r Record) sameAs(o Record) bool {
+	r.TTL, o.TTL = 0, 0
+	r.Host, o.Host = strings.ToLower(r.Host), strings.ToLower(o.Host)
+	return r == o
+}
+
+// supportedTypes are the record types accepted when creating records.
+var supportedTypes = map[string]bool{
+	"A": true, "AAAA": true, "ANAME": true, "CAA": true, "CNAME": true,
+	"DNAME": true, "DS": true, "HTTPS": true, "MX": true, "NS": true,
+	"PTR": true, "SRV": true, "SSHFP": true, "SVCB": true, "TLSA": true,
+	"TXT": true,
+}
+
+// Server emulates the auth and DNS v2 APIs. Its fields must not be changed
+// after the first request is made.
+type Server struct {
+	*httptest.Server
+
+	KeyID         string        // Key ID accepted by the login endpoint
+	Secret        string        // Secret accepted by the login endpoint
+	TokenLifetime time.Duration // Lifetime of issued tokens, one hour by default
+
+	mu       sync.Mutex
+	zones    map[string][]Record
+	tokens   map[string]time.Time
+	failures []failure
+	logins   int
+	requests int
+}
+
+type failure struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// NewServer starts a Server accepting DefaultKeyID and DefaultSecret. The
+// caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		KeyID:         DefaultKeyID,
+		Secret:        DefaultSecret,
+		TokenLifetime: time.Hour,
+		zones:         make(map[string][]Record),
+		tokens:        make(map[string]time.Time),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// APIURL returns the base URL of the emulated DNS API.
+func (s *Server) APIURL() string {
+	return s.URL + "/dns/v2"
+}
+
+// AuthURL returns the URL of the emulated token endpoint.
+func (s *Server) AuthURL() string {
+	return s.URL + "/login"
+}
+
+// AddZone creates zone, replacing any existing zone of the same name, and
+// populates it with records. Records are stored as given, without the
+// validation applied to records created through the API.
+func (s *Server) AddZone(zone string, records ...Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.zones[normalizeZone(zone)] = append([]Record(nil), records...)
+}
+
+// Records returns a copy of the records in zone, or nil if it does not exist.
+func (s *Server) Records(zone string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Record(nil), s.zones[normalizeZone(zone)]...)
+}
+
+// RevokeTokens invalidates every issued token, so that the next API request
+// made with one fails with 401 Unauthorized.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]time.Time)
+}
+
+// FailNext makes the next n DNS API requests fail with status and a JSON
+// error body, before any other processing.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{
+			status: status,
+			body:   fmt.Sprintf(`{"error":%q}`, http.StatusText(status)),
+		})
+	}
+}
+
+// FailNextRetryAfter is like FailNext, but the responses also carry a
+// Retry-After header asking the client to wait for seconds.
+func (s *Server) FailNextRetryAfter(n, status, seconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{
+			status:     status,
+			body:       fmt.Sprintf(`{"error":%q}`, http.StatusText(status)),
+			retryAfter: strconv.Itoa(seconds),
+		})
+	}
+}
+
+// Logins returns the number of successful logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// Requests returns the number of DNS API requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func normalizeZone(zone string) string {
+	return strings.ToLower(strings.TrimSuffix(zone, "."))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeErrors(w http.ResponseWriter, status int, msgs []string) {
+	writeJSON(w, status, map[string][]string{"errors": msgs})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/login":
+		s.serveLogin(w, r)
+	case strings.HasPrefix(r.URL.Path, "/dns/v2/"):
+		s.serveAPI(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	keyID, secret, ok := r.BasicAuth()
+	if !ok || keyID != s.KeyID || secret != s.Secret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "Invalid API key or secret",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "unsupported_grant_type",
+			"error_description": "grant_type must be client_credentials",
+		})
+		return
+	}
+
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	s.tokens[token] = time.Now().Add(s.TokenLifetime)
+	s.logins++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"expires_in":   int(s.TokenLifetime.Seconds()),
+		"token_type":   "bearer",
+	})
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		_, _ = w.Write([]byte(f.body))
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if expires, ok := s.tokens[token]; !ok || time.Now().After(expires) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Split the escaped path so that escaped slashes stay within a segment.
+	var segments []string
+	for _, seg := range strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/dns/v2/"), "/") {
+		unescaped, err := url.PathUnescape(seg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid path")
+			return
+		}
+		segments = append(segments, unescaped)
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "zones":
+		s.serveZones(w, r)
+	case len(segments) == 2 && segments[0] == "dynamic":
+		s.serveDynamic(w, r, normalizeZone(segments[1]))
+	case len(segments) >= 3 && len(segments) <= 5 && segments[0] == "zones" && segments[2] == "records":
+		var host, recType string
+		if len(segments) > 3 {
+			host = segments[3]
+		}
+		if len(segments) > 4 {
+			recType = segments[4]
+		}
+		s.serveRecords(w, r, normalizeZone(segments[1]), host, recType)
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *Server) serveZones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	zones := make([]string, 0, len(s.zones))
+	for zone := range s.zones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	writeJSON(w, http.StatusOK, map[string][]string{"zones": zones})
+}
+
+// serveDynamic sets the A or AAAA record of fqdn to the address the request
+// came from, replacing any existing records of that type.
+func (s *Server) serveDynamic(w http.ResponseWriter, r *http.Request, fqdn string) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	zone := ""
+	for candidate := range s.zones {
+		if (fqdn == candidate || strings.HasSuffix(fqdn, "."+candidate)) && len(candidate) > len(zone) {
+			zone = candidate
+		}
+	}
+	if zone == "" {
+		writeError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+
+	host := "@"
+	if fqdn != zone {
+		host = strings.TrimSuffix(fqdn, "."+zone)
+	}
+
+	addrHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Unable to determine client address")
+		return
+	}
+	ip := net.ParseIP(addrHost)
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, "Unable to determine client address")
+		return
+	}
+	recType := "AAAA"
+	if ip.To4() != nil {
+		recType = "A"
+	}
+
+	records, removed := removeRecords(s.zones[zone], selection{host: host, recType: recType})
+	records, added := addRecords(records, []Record{{Host: host, Type: recType, Data: ip.String(), TTL: DefaultTTL}})
+	s.zones[zone] = records
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"records_added":   added,
+		"records_removed": removed,
+		"message":         fmt.Sprintf("%s %s set to %s", fqdn, recType, ip),
+	})
+}
+
+// selection is the set of records addressed by a request, from the host and
+// type in its path and its select and exclude options.
+type selection struct {
+	host             string
+	recType          string
+	selects          []url.Values
+	excludeTemplate  bool
+	excludeGenerated bool
+}
+
+func newSelection(r *http.Request, host, recType string) (selection, error) {
+	query := r.URL.Query()
+	sel := selection{host: host, recType: recType}
+	_, sel.excludeTemplate = query["exclude-template"]
+	_, sel.excludeGenerated = query["exclude-generated"]
+
+	for _, raw := range query["select"] {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			return selection{}, fmt.Errorf("Invalid select: %s", raw)
+		}
+		for key := range values {
+			if _, ok := selectFields[key]; !ok {
+				return selection{}, fmt.Errorf("Invalid select field: %s", key)
+			}
+		}
+		sel.selects = append(sel.selects, values)
+	}
+	return sel, nil
+}
+
+// selectFields returns the value of each record field which can be used in
+// a select option, formatted as in a query string.
+var selectFields = map[string]func(Record) string{
+	"host":            func(r Record) string { return r.Host },
+	"type":            func(r Record) string { return r.Type },
+	"data":            func(r Record) string { return r.Data },
+	"ttl":             func(r Record) string { return strconv.Itoa(r.TTL) },
+	"mx_priority":     func(r Record) string { return strconv.Itoa(int(r.MXPriority)) },
+	"srv_priority":    func(r Record) string { return strconv.Itoa(int(r.SRVPriority)) },
+	"srv_weight":      func(r Record) string { return strconv.Itoa(int(r.SRVWeight)) },
+	"srv_port":        func(r Record) string { return strconv.Itoa(int(r.SRVPort)) },
+	"caa_flags":       func(r Record) string { return strconv.Itoa(int(r.CAAFlags)) },
+	"caa_tag":         func(r Record) string { return r.CAATag },
+	"sshfp_algorithm": func(r Record) string { return strconv.Itoa(int(r.SSHFPAlgorithm)) },
+	"sshfp_type":      func(r Record) string { return strconv.Itoa(int(r.SSHFPType)) },
+	"tlsa_usage":      func(r Record) string { return strconv.Itoa(int(r.TLSAUsage)) },
+	"tlsa_selector":   func(r Record) string { return strconv.Itoa(int(r.TLSASelector)) },
+	"tlsa_matching":   func(r Record) string { return strconv.Itoa(int(r.TLSAMatching)) },
+	"ds_key_tag":      func(r Record) string { return strconv.Itoa(int(r.DSKeyTag)) },
+	"ds_algorithm":    func(r Record) string { return strconv.Itoa(int(r.DSAlgorithm)) },
+	"ds_digest_type":  func(r Record) string { return strconv.Itoa(int(r.DSDigestType)) },
+	"svcb_priority":   func(r Record) string { return strconv.Itoa(int(r.SVCBPriority)) },
+	"svcb_params":     func(r Record) string { return r.SVCBParams },
+}
+
+// matches reports whether rec is addressed by sel.
+func (sel selection) matches(rec Record) bool {
+	if sel.excludeTemplate && rec.Template {
+		return false
+	}
+	if sel.excludeGenerated && rec.Generated {
+		return false
+	}
+	if !matchesFilter(rec, sel.host, sel.recType, "") {
+		return false
+	}
+	if len(sel.selects) == 0 {
+		return true
+	}
+	for _, values := range sel.selects {
+		if matchesSelect(rec, values) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSelect reports whether rec has every field given in a select
+// option. The host is compared case-insensitively.
+func matchesSelect(rec Record, values url.Values) bool {
+	for key := range values {
+		want := values.Get(key)
+		if key == "host" {
+			if !strings.EqualFold(rec.Host, want) {
+				return false
+			}
+			continue
+		}
+		if selectFields[key](rec) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesFilter reports whether rec has the given host, type and data, where
+// an empty filter matches anything.
+func matchesFilter(rec Record, host, recType, data string) bool {
+	if host != "" && !strings.EqualFold(rec.Host, host) {
+		return false
+	}
+	if recType != "" && rec.Type != recType {
+		return false
+	}
+	if data != "" && rec.Data != data {
+		return false
+	}
+	return true
+}
+
+func (s *Server) serveRecords(w http.ResponseWriter, r *http.Request, zone, host, recType string) {
+	records, ok := s.zones[zone]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+
+	sel, err := newSelection(r, host, recType)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		matched := []Record{}
+		for _, rec := range records {
+			if sel.matches(rec) {
+				matched = append(matched, rec)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string][]Record{"records": matched})
+
+	case http.MethodPost:
+		added, ok := s.readRecords(w, r, host, recType)
+		if !ok {
+			return
+		}
+		records, count := addRecords(records, added)
+		s.zones[zone] = records
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"records_added": count,
+			"message":       fmt.Sprintf("%d records added", count),
+		})
+
+	case http.MethodPut:
+		added, ok := s.readRecords(w, r, host, recType)
+		if !ok {
+			return
+		}
+		records, removed := removeRecords(records, sel)
+		records, count := addRecords(records, added)
+		s.zones[zone] = records
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"records_added":   count,
+			"records_removed": removed,
+			"message":         fmt.Sprintf("%d records added, %d records removed", count, removed),
+		})
+
+	case http.MethodDelete:
+		records, removed := removeRecords(records, sel)
+		s.zones[zone] = records
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"records_removed": removed,
+			"message":         fmt.Sprintf("%d records removed", removed),
+		})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// readRecords decodes and validates the records in a request body, filling
+// in the host and type from the path where they are omitted.
+func (s *Server) readRecords(w http.ResponseWriter, r *http.Request, host, recType string) ([]Record, bool) {
+	var body struct {
+		Records []Record `json:"records"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return nil, false
+	}
+
+	var errs []string
+	for i := range body.Records {
+		rec := &body.Records[i]
+		if rec.Host == "" {
+			rec.Host = host
+		}
+		if rec.Type == "" {
+			rec.Type = recType
+		}
+		if rec.TTL == 0 {
+			rec.TTL = DefaultTTL
+		}
+		rec.Template, rec.Generated = false, false
+
+		switch {
+		case rec.Host == "":
+			errs = append(errs, fmt.Sprintf("Record %d: missing host", i))
+		case !supportedTypes[rec.Type]:
+			errs = append(errs, fmt.Sprintf("Record %d: unsupported record type '%s'", i, rec.Type))
+		case rec.Data == "":
+			errs = append(errs, fmt.Sprintf("Record %d: missing data", i))
+		}
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return nil, false
+	}
+	return body.Records, true
+}
+
+// addRecords appends the records in added which are not already present,
+// returning the new record list and the number added.
+func addRecords(records, added []Record) ([]Record, int) {
+	count := 0
+	for _, rec := range added {
+		duplicate := false
+		for _, existing := range records {
+			if existing.sameAs(rec) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			records = append(records, rec)
+			count++
+		}
+	}
+	return records, count
+}
+
+// removeRecords removes the records matched by sel, returning the remaining
+// records and the number removed.
+func removeRecords(records []Record, sel selection) ([]Record, int) {
+	remaining := make([]Record, 0, len(records))
+	for _, rec := range records {
+		if !sel.matches(rec) {
+			remaining = append(remaining, rec)
+		}
+	}
+	return remaining, len(records) - len(remaining)
+}
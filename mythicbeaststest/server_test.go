@@ -0,0 +1,247 @@
+package mythicbeaststest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+// login returns a bearer token from srv.
+func login(t *testing.T, srv *mythicbeaststest.Server) string {
+	t.Helper()
+
+	req, _ := http.NewRequest("POST", srv.AuthURL(), strings.NewReader("grant_type=client_credentials"))
+	req.SetBasicAuth(srv.KeyID, srv.Secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AccessToken == "" {
+		t.Fatalf("login: status %d, %v", resp.StatusCode, err)
+	}
+	return body.AccessToken
+}
+
+// do makes an API request with token, returning the status and body.
+func do(t *testing.T, srv *mythicbeaststest.Server, token, method, path, body string) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, srv.APIURL()+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestLogin(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		keyID      string
+		secret     string
+		grant      string
+		wantStatus int
+	}{
+		{"valid", srv.KeyID, srv.Secret, "client_credentials", http.StatusOK},
+		{"wrong secret", srv.KeyID, "wrong", "client_credentials", http.StatusUnauthorized},
+		{"wrong grant", srv.KeyID, srv.Secret, "password", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", srv.AuthURL(), strings.NewReader(url.Values{"grant_type": {tt.grant}}.Encode()))
+			req.SetBasicAuth(tt.keyID, tt.secret)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	if logins := srv.Logins(); logins != 1 {
+		t.Errorf("counted %d logins, want 1", logins)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	if status, _ := do(t, srv, "", "GET", "/zones", ""); status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", status)
+	}
+
+	token := login(t, srv)
+	if status, body := do(t, srv, token, "GET", "/zones", ""); status != http.StatusOK || !strings.Contains(body, `"example.com"`) {
+		t.Errorf("with a token: status %d, body %s", status, body)
+	}
+
+	srv.RevokeTokens()
+	if status, _ := do(t, srv, token, "GET", "/zones", ""); status != http.StatusUnauthorized {
+		t.Errorf("after RevokeTokens: status %d, want 401", status)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+	token := login(t, srv)
+
+	srv.FailNext(2, http.StatusServiceUnavailable)
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if status, _ := do(t, srv, token, "GET", "/zones", ""); status != want {
+			t.Errorf("request %d: status %d, want %d", i, status, want)
+		}
+	}
+	if requests := srv.Requests(); requests != 3 {
+		t.Errorf("counted %d requests, want 3", requests)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	zone := []mythicbeaststest.Record{
+		{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+		{Host: "www", Type: "AAAA", Data: "2001:db8::1", TTL: 300},
+		{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 10},
+		{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 20},
+		{Host: "@", Type: "NS", Data: "ns1.mythic-beasts.com.", TTL: 300, Template: true},
+		{Host: "@", Type: "TXT", Data: "generated", TTL: 300, Generated: true},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string   // A substring of the response
+		wantHosts  []string // Host and type of each record left in the zone
+	}{
+		{
+			name:   "get by host",
+			method: "GET", path: "/zones/example.com/records/WWW",
+			wantStatus: http.StatusOK, wantBody: `"2001:db8::1"`,
+		},
+		{
+			name:   "get excluding template and generated",
+			method: "GET", path: "/zones/example.com/records/@?exclude-template&exclude-generated",
+			wantStatus: http.StatusOK, wantBody: `"mx_priority":20`,
+		},
+		{
+			name:   "unknown zone",
+			method: "GET", path: "/zones/example.org/records",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "invalid select field",
+			method: "GET", path: "/zones/example.com/records?select=" + url.QueryEscape("colour=red"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "add",
+			method: "POST", path: "/zones/example.com/records",
+			body:       `{"records":[{"host":"new","type":"TXT","data":"hello"},{"host":"www","type":"A","data":"192.0.2.1"}]}`,
+			wantStatus: http.StatusOK, wantBody: `"records_added":1`,
+			wantHosts: []string{"www A", "www AAAA", "@ MX", "@ MX", "@ NS", "@ TXT", "new TXT"},
+		},
+		{
+			name:   "add invalid",
+			method: "POST", path: "/zones/example.com/records",
+			body:       `{"records":[{"type":"TXT","data":"x"},{"host":"a","type":"WKS","data":"x"}]}`,
+			wantStatus: http.StatusBadRequest, wantBody: "unsupported record type",
+			wantHosts: []string{"www A", "www AAAA", "@ MX", "@ MX", "@ NS", "@ TXT"},
+		},
+		{
+			name:   "replace",
+			method: "PUT", path: "/zones/example.com/records/www/A",
+			body:       `{"records":[{"data":"192.0.2.2"}]}`,
+			wantStatus: http.StatusOK, wantBody: `"records_removed":1`,
+			wantHosts: []string{"www AAAA", "@ MX", "@ MX", "@ NS", "@ TXT", "www A"},
+		},
+		{
+			name:   "delete by type-specific field",
+			method: "DELETE", path: "/zones/example.com/records?select=" + url.QueryEscape("host=@&type=MX&data=mail.example.com.&mx_priority=10"),
+			wantStatus: http.StatusOK, wantBody: `"records_removed":1`,
+			wantHosts: []string{"www A", "www AAAA", "@ MX", "@ NS", "@ TXT"},
+		},
+		{
+			name:   "delete excluding template",
+			method: "DELETE", path: "/zones/example.com/records/@?exclude-template&exclude-generated",
+			wantStatus: http.StatusOK, wantBody: `"records_removed":2`,
+			wantHosts: []string{"www A", "www AAAA", "@ NS", "@ TXT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com", zone...)
+
+			status, body := do(t, srv, login(t, srv), tt.method, tt.path, tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+
+			if tt.wantHosts == nil {
+				return
+			}
+			var hosts []string
+			for _, r := range srv.Records("example.com") {
+				hosts = append(hosts, r.Host+" "+r.Type)
+			}
+			if strings.Join(hosts, ", ") != strings.Join(tt.wantHosts, ", ") {
+				t.Errorf("zone holds %q, want %q", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestDynamic(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", mythicbeaststest.Record{Host: "home", Type: "A", Data: "192.0.2.1", TTL: 300})
+
+	status, body := do(t, srv, login(t, srv), "PUT", "/dynamic/home.example.com", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+
+	records := srv.Records("example.com")
+	if len(records) != 1 || records[0].Data != "127.0.0.1" {
+		t.Errorf("zone holds %+v, want home A 127.0.0.1", records)
+	}
+}
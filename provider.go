@@ -2,14 +2,15 @@ package mythicbeasts
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"net/url"
+	"log/slog"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/libdns/libdns"
-	"golang.org/x/net/publicsuffix"
 )
 
 // Provider facilitates DNS record manipulation with Mythic Beasts.
@@ -17,10 +18,75 @@ type Provider struct {
 	KeyID  string `json:"key_id,omitempty"`
 	Secret string `json:"secret,omitempty"`
 
+	// APIURL is the base URL of the DNS API, for example to use a staging
+	// endpoint. Defaults to https://api.mythic-beasts.com/dns/v2.
+	APIURL string `json:"api_url,omitempty"`
+
+	// AuthURL is the URL used to obtain bearer tokens. Defaults to
+	// https://auth.mythic-beasts.com/login.
+	AuthURL string `json:"auth_url,omitempty"`
+
+	// HTTPClient is used for requests to both the auth and DNS APIs. If nil,
+	// a client with a 30 second timeout is used.
+	HTTPClient *http.Client `json:"-"`
+
+	// Logger, if set, receives a record of each API request and login,
+	// mostly at debug level. The secret and bearer tokens are never logged.
+	Logger *slog.Logger `json:"-"`
+
+	// TokenStore shares bearer tokens with other Providers or processes
+	// using the same KeyID, so they need not log in again while a token is
+	// valid. If nil, tokens are only held by this Provider.
+	TokenStore TokenStore `json:"-"`
+
+	// ExcludeTemplate and ExcludeGenerated omit records which come from a
+	// zone template or are generated by Mythic Beasts from GetRecords. Such
+	// records cannot be changed through the API. Either way, the source of
+	// most returned records is available as a RecordSource in ProviderData;
+	// see RecordSource for the exceptions.
+	ExcludeTemplate  bool `json:"exclude_template,omitempty"`
+	ExcludeGenerated bool `json:"exclude_generated,omitempty"`
+
+	// MaxRetries is the number of times an idempotent request (GET, PUT or
+	// DELETE) is retried after a connection error, 429 or 5xx response.
+	// Zero means 3 retries and a negative value disables retrying.
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// RetryBackoff is the delay before the first retry, which doubles with
+	// each further attempt up to 10 seconds. A Retry-After header from the
+	// API takes precedence. Defaults to 500ms.
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty"`
+
+	// MaxRetryAfter is the longest Retry-After which is waited for. If the
+	// API asks for a longer wait, or one beyond the context deadline, the
+	// error is returned at once instead. Defaults to 30 seconds.
+	MaxRetryAfter time.Duration `json:"max_retry_after,omitempty"`
+
+	// RateLimit is the average number of requests per second made to the
+	// auth and DNS APIs, shared by all goroutines using this Provider.
+	// RateBurst requests may be made at once after a quiet period; it
+	// defaults to RateLimit rounded up, or 1. Zero means no limit.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	RateBurst int     `json:"rate_burst,omitempty"`
+
+	// ZoneCacheTTL is how long the account's zone list is relied on to find
+	// the zone serving a name below a hosted zone, after which it is listed
+	// again in case a more specific zone has been added. Defaults to 5
+	// minutes.
+	ZoneCacheTTL time.Duration `json:"zone_cache_ttl,omitempty"`
+
 	token          mythicAuthResponse
 	tokenExpiresAt time.Time
+	tokenMutex     sync.Mutex // Guards token, tokenExpiresAt and loginCall
+	loginCall      *loginCall // The login in progress, if any
+	memoryTokens   MemoryTokenStore
 
-	mutex sync.Mutex
+	zones     []string               // Cached zone names from ListZones, used by resolveZone
+	zonesAt   time.Time              // When zones was listed
+	zoneLocks map[string]*sync.Mutex // Serialise writes to each hosted zone; see lockZone
+	limiter   *rateLimiter           // Created on first use from RateLimit and RateBurst
+
+	mutex sync.Mutex // Guards zones, zoneLocks and limiter
 }
 
 // unFQDN trims any trailing "." from fqdn.
@@ -28,39 +94,58 @@ func (p *Provider) unFQDN(fqdn string) string {
 	return strings.TrimSuffix(fqdn, ".")
 }
 
-// GetRecords lists all records in given zone.
+// GetRecords lists all records in given zone. The zone may be any name at
+// or below a zone hosted by the account, in which case only records at or
+// below that name are returned. Template and generated records are included
+// unless excluded by ExcludeTemplate and ExcludeGenerated.
 func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	return p.listRecords(ctx, "GetRecords", zone, recordQuery{
+		excludeTemplate:  p.ExcludeTemplate,
+		excludeGenerated: p.ExcludeGenerated,
+	})
+}
+
+// GetRecordsFiltered lists the records in zone with the given name and type,
+// without fetching the whole zone. An empty name or type matches any. Like
+// GetRecords, template and generated records are included unless excluded by
+// ExcludeTemplate and ExcludeGenerated.
+func (p *Provider) GetRecordsFiltered(ctx context.Context, zone, name, recType string) ([]libdns.Record, error) {
+	return p.listRecords(ctx, "GetRecordsFiltered", zone, recordQuery{
+		host:             name,
+		recType:          recType,
+		excludeTemplate:  p.ExcludeTemplate,
+		excludeGenerated: p.ExcludeGenerated,
+	})
+}
+
+// listRecords implements GetRecords and GetRecordsFiltered. The host in
+// query is a name relative to zone.
+func (p *Provider) listRecords(ctx context.Context, op, zone string, query recordQuery) ([]libdns.Record, error) {
 	err := p.login(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("login: provider login failed: %d", err)
+		return nil, fmt.Errorf("login: provider login failed: %w", err)
 	}
 
-	formatedZone, err := publicsuffix.EffectiveTLDPlusOne(p.unFQDN(zone))
+	hosted, err := p.resolveZone(ctx, zone)
 	if err != nil {
-		return nil, fmt.Errorf("Provided zone string malformed %d", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	respBody, err := p.doAPIRequest(ctx, "GET", apiURL+"/zones/"+url.PathEscape(formatedZone)+"/records", nil)
-	if err != nil {
-		return nil, fmt.Errorf("GetRecords: %w", err)
+	if query.host != "" {
+		query.host = hosted.host(query.host)
 	}
 
-	result := mythicRecords{}
-
-	err = result.UnmarshalJSON(respBody)
+	result, err := p.queryRecords(ctx, op, hosted, query)
 	if err != nil {
-		return nil, fmt.Errorf("GetRecords: failed to unmarshal response: %d", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	var records []libdns.Record
 
-	for _, r := range result.Records {
+	for _, r := range hosted.fromHosts(result) {
 		record, err := r.GetLibdnsRecord()
 		if err != nil {
-			return nil, fmt.Errorf("GetRecords: failed to parse record %s: %d", r.GetName(), err)
+			return nil, fmt.Errorf("%s: failed to parse record %s: %w", op, r.GetName(), err)
 		}
 
 		records = append(records, record)
@@ -68,77 +153,124 @@ func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record
 	return records, nil
 }
 
-// AppendRecords adds records to the zone. It returns the records that were added.
+// AppendRecords adds records to the zone. It returns the records that were added,
+// as stored by the API. The records are validated before anything is sent,
+// and if any are invalid a *ValidationError listing them is returned. If the
+// API reports adding a different number of records than were given, the
+// records found in the zone are returned along with an error.
 func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	err := p.login(ctx)
+	data := mythicRecords{}
+	err := data.FromLibdns(records)
+	if err != nil {
+		return nil, fmt.Errorf("AppendRecords: %w", err)
+	}
+
+	err = p.login(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("login: provider login failed: %d", err)
+		return nil, fmt.Errorf("login: provider login failed: %w", err)
 	}
 
-	formatedZone, err := publicsuffix.EffectiveTLDPlusOne(p.unFQDN(zone))
+	hosted, err := p.resolveZone(ctx, zone)
 	if err != nil {
-		return nil, fmt.Errorf("Provided zone string malformed %d", err)
+		return nil, fmt.Errorf("AppendRecords: %w", err)
 	}
 
 	// Batch add records
-	appendedRecords, err := p.addRecords(ctx, formatedZone, records)
+	appendedRecords, err := p.addRecords(ctx, hosted, records, data)
 	if err != nil {
-		return nil, fmt.Errorf("AppendRecords: %d", err)
+		return appendedRecords, fmt.Errorf("AppendRecords: %w", err)
 	}
 
 	return appendedRecords, nil
 }
 
 // SetRecords sets the records in the zone, either by updating existing records or creating new ones.
-// It returns the updated records.
+// It returns the records stored by the API for each name and type that was set. As with
+// AppendRecords, invalid records are reported in a *ValidationError before anything is sent,
+// and the stored records are returned alongside the error if the API adds an unexpected number.
 func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	err := p.login(ctx)
+	data := mythicRecords{}
+	err := data.FromLibdns(records)
+	if err != nil {
+		return nil, fmt.Errorf("SetRecords: %w", err)
+	}
+
+	err = p.login(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("login: provider login failed: %d", err)
+		return nil, fmt.Errorf("login: provider login failed: %w", err)
 	}
 
-	formatedZone, err := publicsuffix.EffectiveTLDPlusOne(p.unFQDN(zone))
+	hosted, err := p.resolveZone(ctx, zone)
 	if err != nil {
-		return nil, fmt.Errorf("Provided zone string malformed %d", err)
+		return nil, fmt.Errorf("SetRecords: %w", err)
 	}
 
 	// Atomic set records
-	setRecord, err := p.setRecordsAtomic(ctx, formatedZone, records)
+	setRecord, err := p.setRecordsAtomic(ctx, hosted, data)
 	if err != nil {
-		return nil, fmt.Errorf("SetRecords: %d", err)
+		return setRecord, fmt.Errorf("SetRecords: %w", err)
 	}
 	return setRecord, nil
 }
 
 // DeleteRecords deletes the records from the zone. It returns the records that were deleted.
+// Only records whose data and TTL match are deleted; an empty type, data or TTL matches any
+// value, so other records in the same RRset are left intact.
+//
+// The records are found with one API call and deleted with another, however
+// many there are, unless they are too many to select in one URL. If some of
+// them cannot be deleted, or the API removes other records with them, the
+// records that were deleted are returned along with a *DeleteError listing
+// the ones that were not and any removed unexpectedly.
 func (p *Provider) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
 	err := p.login(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("login: provider login failed: %d", err)
+		return nil, fmt.Errorf("login: provider login failed: %w", err)
 	}
 
-	formatedZone, err := publicsuffix.EffectiveTLDPlusOne(p.unFQDN(zone))
+	hosted, err := p.resolveZone(ctx, zone)
 	if err != nil {
-		return nil, fmt.Errorf("Provided zone string malformed %d", err)
+		return nil, fmt.Errorf("DeleteRecords: %w", err)
 	}
 
-	var deletedRecords []libdns.Record
-
-	for _, record := range records {
-		deletedRecord, err := p.removeRecord(ctx, formatedZone, record)
-		if err != nil {
-			return deletedRecords, fmt.Errorf("DeleteRecords: %d", err)
-		}
-		deletedRecords = append(deletedRecords, deletedRecord...)
+	deletedRecords, err := p.removeRecords(ctx, hosted, records)
+	if err != nil {
+		return deletedRecords, fmt.Errorf("DeleteRecords: %w", err)
 	}
 
 	return deletedRecords, nil
 }
 
+// ListZones lists all zones the API key is permitted to manage.
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	err := p.login(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("login: provider login failed: %w", err)
+	}
+
+	respBody, err := p.doAPIRequest(ctx, "ListZones", "", "GET", p.apiURL()+"/zones", nil)
+	if err != nil {
+		return nil, fmt.Errorf("ListZones: %w", err)
+	}
+
+	result := mythicZones{}
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("ListZones: failed to unmarshal response: %w", err)
+	}
+
+	zones := make([]libdns.Zone, 0, len(result.Zones))
+	for _, zone := range result.Zones {
+		zones = append(zones, libdns.Zone{Name: p.unFQDN(zone) + "."})
+	}
+	return zones, nil
+}
+
 // Interface guards
 var (
 	_ libdns.RecordGetter   = (*Provider)(nil)
 	_ libdns.RecordAppender = (*Provider)(nil)
 	_ libdns.RecordSetter   = (*Provider)(nil)
 	_ libdns.RecordDeleter  = (*Provider)(nil)
+	_ libdns.ZoneLister     = (*Provider)(nil)
 )
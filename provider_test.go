@@ -0,0 +1,381 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+// newProvider returns a Provider using srv, with retries made quickly.
+func newProvider(srv *mythicbeaststest.Server) *mythicbeasts.Provider {
+	return &mythicbeasts.Provider{
+		KeyID:        srv.KeyID,
+		Secret:       srv.Secret,
+		APIURL:       srv.APIURL(),
+		AuthURL:      srv.AuthURL(),
+		RetryBackoff: time.Millisecond,
+	}
+}
+
+func TestDeleteRecordsKeepsSiblings(t *testing.T) {
+	tests := []struct {
+		name   string
+		zone   []mythicbeaststest.Record
+		delete libdns.Record
+		kept   mythicbeaststest.Record
+	}{
+		{
+			name: "CAA tag",
+			zone: []mythicbeaststest.Record{
+				{Host: "@", Type: "CAA", Data: "letsencrypt.org", TTL: 300, CAATag: "issue"},
+				{Host: "@", Type: "CAA", Data: "letsencrypt.org", TTL: 300, CAATag: "issuewild"},
+			},
+			delete: libdns.CAA{Name: "@", Tag: "issue", Value: "letsencrypt.org"},
+			kept:   mythicbeaststest.Record{Host: "@", Type: "CAA", Data: "letsencrypt.org", TTL: 300, CAATag: "issuewild"},
+		},
+		{
+			name: "MX priority",
+			zone: []mythicbeaststest.Record{
+				{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 10},
+				{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 20},
+			},
+			delete: libdns.MX{Name: "@", Preference: 10, Target: "mail.example.com."},
+			kept:   mythicbeaststest.Record{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 20},
+		},
+		{
+			name: "SRV port",
+			zone: []mythicbeaststest.Record{
+				{Host: "_sip._tcp", Type: "SRV", Data: "sip.example.com.", TTL: 300, SRVPriority: 10, SRVWeight: 5, SRVPort: 5060},
+				{Host: "_sip._tcp", Type: "SRV", Data: "sip.example.com.", TTL: 300, SRVPriority: 10, SRVWeight: 5, SRVPort: 5061},
+			},
+			delete: libdns.SRV{Service: "sip", Transport: "tcp", Name: "@", Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com."},
+			kept:   mythicbeaststest.Record{Host: "_sip._tcp", Type: "SRV", Data: "sip.example.com.", TTL: 300, SRVPriority: 10, SRVWeight: 5, SRVPort: 5061},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com", tt.zone...)
+
+			deleted, err := newProvider(srv).DeleteRecords(context.Background(), "example.com.", []libdns.Record{tt.delete})
+			if err != nil {
+				t.Fatalf("DeleteRecords: %v", err)
+			}
+			if len(deleted) != 1 {
+				t.Errorf("deleted %d records, want 1", len(deleted))
+			}
+
+			records := srv.Records("example.com")
+			if len(records) != 1 || records[0] != tt.kept {
+				t.Errorf("zone holds %+v, want only %+v", records, tt.kept)
+			}
+		})
+	}
+}
+
+// proxy forwards requests to srv, passing each through rewrite first, and
+// records the method and URI of every request.
+type proxy struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	paths []string
+}
+
+func newProxy(t *testing.T, srv *mythicbeaststest.Server, rewrite func(*http.Request)) *proxy {
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverse := httputil.NewSingleHostReverseProxy(target)
+
+	p := &proxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.paths = append(p.paths, r.Method+" "+r.URL.RequestURI())
+		p.mu.Unlock()
+		if rewrite != nil {
+			rewrite(r)
+		}
+		reverse.ServeHTTP(w, r)
+	}))
+	return p
+}
+
+func (p *proxy) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.paths...)
+}
+
+func TestDeleteRecordsFetchesOnlyTargets(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com",
+		mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+		mythicbeaststest.Record{Host: "www", Type: "AAAA", Data: "2001:db8::1", TTL: 300},
+		mythicbeaststest.Record{Host: "mail", Type: "A", Data: "192.0.2.2", TTL: 300},
+		mythicbeaststest.Record{Host: "old", Type: "TXT", Data: "a", TTL: 300},
+		mythicbeaststest.Record{Host: "old", Type: "TXT", Data: "b", TTL: 300},
+	)
+
+	px := newProxy(t, srv, nil)
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+
+	deleted, err := provider.DeleteRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "www", IP: netip.MustParseAddr("192.0.2.1")},
+		libdns.RR{Name: "old"},
+	})
+	if err != nil {
+		t.Fatalf("DeleteRecords: %v", err)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("deleted %d records, want 3", len(deleted))
+	}
+
+	var records []string
+	for _, req := range px.requests() {
+		if strings.Contains(req, "/records") {
+			records = append(records, req)
+		}
+	}
+	if len(records) != 2 || !strings.HasPrefix(records[0], "GET ") || !strings.HasPrefix(records[1], "DELETE ") {
+		t.Fatalf("made record requests %q, want a GET and a DELETE", records)
+	}
+	get, _ := url.Parse(strings.TrimPrefix(records[0], "GET "))
+	want := []string{"host=www&type=A", "host=old"}
+	if selects := get.Query()["select"]; !reflect.DeepEqual(selects, want) {
+		t.Errorf("fetched %q, want %q", selects, want)
+	}
+
+	if records := srv.Records("example.com"); len(records) != 2 {
+		t.Errorf("zone holds %+v, want www AAAA and mail A", records)
+	}
+}
+
+func TestDeleteRecordsBatchesRequests(t *testing.T) {
+	const count = 200
+
+	var zone []mythicbeaststest.Record
+	var records []libdns.Record
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("stale%d", i)
+		zone = append(zone, mythicbeaststest.Record{Host: name, Type: "TXT", Data: "old", TTL: 300})
+		records = append(records, libdns.TXT{Name: name, Text: "old"})
+	}
+
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", zone...)
+
+	deleted, err := newProvider(srv).DeleteRecords(context.Background(), "example.com.", records)
+	if err != nil {
+		t.Fatalf("DeleteRecords: %v", err)
+	}
+	if len(deleted) != count {
+		t.Errorf("deleted %d records, want %d", len(deleted), count)
+	}
+	if remaining := srv.Records("example.com"); len(remaining) != 0 {
+		t.Errorf("zone holds %d records after deleting, want none", len(remaining))
+	}
+
+	// Listing zones and a few batches of fetches and deletes, rather than
+	// requests for each name.
+	if requests := srv.Requests(); requests > 7 {
+		t.Errorf("made %d requests, want at most 7", requests)
+	}
+}
+
+func TestDeleteRecordsReportsUnexpectedRemovals(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com",
+		mythicbeaststest.Record{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 10},
+		mythicbeaststest.Record{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 300, MXPriority: 20},
+	)
+
+	// Reduce each select option to its host, type and data, as an API which
+	// ignored the type-specific fields would.
+	px := newProxy(t, srv, func(r *http.Request) {
+		if r.Method != http.MethodDelete {
+			return
+		}
+		query := r.URL.Query()
+		for i, raw := range query["select"] {
+			values, _ := url.ParseQuery(raw)
+			query["select"][i] = url.Values{
+				"host": {values.Get("host")},
+				"type": {values.Get("type")},
+				"data": {values.Get("data")},
+			}.Encode()
+		}
+		r.URL.RawQuery = query.Encode() + "&exclude-template&exclude-generated"
+	})
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+
+	deleted, err := provider.DeleteRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.MX{Name: "@", Preference: 10, Target: "mail.example.com."},
+	})
+	var deleteErr *mythicbeasts.DeleteError
+	if !errors.As(err, &deleteErr) {
+		t.Fatalf("DeleteRecords returned %v, want a *DeleteError", err)
+	}
+	if len(deleted) != 1 || len(deleteErr.Failed) != 0 {
+		t.Errorf("deleted %d records with %d failures, want 1 and none", len(deleted), len(deleteErr.Failed))
+	}
+	if len(deleteErr.Unexpected) != 1 {
+		t.Fatalf("reported %d unexpected removals, want 1", len(deleteErr.Unexpected))
+	}
+	if mx, ok := deleteErr.Unexpected[0].(libdns.MX); !ok || mx.Preference != 20 {
+		t.Errorf("reported %#v as removed, want MX 20", deleteErr.Unexpected[0])
+	}
+}
+
+func TestWriteCountMismatchReturnsStoredRecords(t *testing.T) {
+	existing := mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300}
+	records := []libdns.Record{
+		libdns.Address{Name: "www", TTL: 300 * time.Second, IP: netip.MustParseAddr("192.0.2.1")},
+		libdns.Address{Name: "www", TTL: 300 * time.Second, IP: netip.MustParseAddr("192.0.2.2")},
+	}
+
+	tests := []struct {
+		name    string
+		write   func(*mythicbeasts.Provider) ([]libdns.Record, error)
+		wantErr string
+	}{
+		{
+			name: "AppendRecords",
+			write: func(p *mythicbeasts.Provider) ([]libdns.Record, error) {
+				return p.AppendRecords(context.Background(), "example.com.", records)
+			},
+			wantErr: "API added 1 records, expected 2",
+		},
+		{
+			name: "SetRecords",
+			write: func(p *mythicbeasts.Provider) ([]libdns.Record, error) {
+				// A duplicate in the input is only stored once.
+				return p.SetRecords(context.Background(), "example.com.", append(records, records[1]))
+			},
+			wantErr: "API added 2 records, expected 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com", existing)
+
+			stored, err := tt.write(newProvider(srv))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+			if len(stored) != 2 {
+				t.Errorf("returned %d records, want the 2 stored", len(stored))
+			}
+		})
+	}
+}
+
+// nonCanonical holds records whose data is not in the form the API stores.
+var nonCanonical = []libdns.Record{
+	libdns.RR{Name: "www", Type: "AAAA", Data: "2001:DB8:0:0::1"},
+	libdns.RR{Name: "host", Type: "SSHFP", Data: "1 2 " + strings.Repeat("ab", 16) + " " + strings.Repeat("ab", 16)},
+	libdns.RR{Name: "_443._tcp", Type: "TLSA", Data: "3 1 1 " + strings.Repeat("CD", 16) + " " + strings.Repeat("cd", 16)},
+}
+
+func TestAppendRecordsMatchesNonCanonicalData(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	added, err := newProvider(srv).AppendRecords(context.Background(), "example.com.", nonCanonical)
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if len(added) != len(nonCanonical) {
+		t.Fatalf("added %+v, want %d records", added, len(nonCanonical))
+	}
+
+	want := []string{"2001:db8::1", "1 2 " + strings.Repeat("ab", 32), "3 1 1 " + strings.Repeat("cd", 32)}
+	for i, record := range added {
+		if data := record.RR().Data; data != want[i] {
+			t.Errorf("record %d has data %q, want %q", i, data, want[i])
+		}
+	}
+}
+
+func TestDeleteRecordsMatchesNonCanonicalData(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com",
+		mythicbeaststest.Record{Host: "www", Type: "AAAA", Data: "2001:db8::1", TTL: 300},
+		mythicbeaststest.Record{Host: "host", Type: "SSHFP", Data: strings.Repeat("ab", 32), TTL: 300, SSHFPAlgorithm: 1, SSHFPType: 2},
+		mythicbeaststest.Record{Host: "_443._tcp", Type: "TLSA", Data: strings.Repeat("cd", 32), TTL: 300, TLSAUsage: 3, TLSASelector: 1, TLSAMatching: 1},
+	)
+
+	deleted, err := newProvider(srv).DeleteRecords(context.Background(), "example.com.", nonCanonical)
+	if err != nil {
+		t.Fatalf("DeleteRecords: %v", err)
+	}
+	if len(deleted) != len(nonCanonical) {
+		t.Errorf("deleted %+v, want %d records", deleted, len(nonCanonical))
+	}
+	if records := srv.Records("example.com"); len(records) != 0 {
+		t.Errorf("zone holds %+v after deleting, want nothing", records)
+	}
+}
+
+func TestWriteCountMismatchKeepsReadBackError(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300})
+
+	// Send the read back after the write to a zone which does not exist.
+	var written atomic.Bool
+	px := newProxy(t, srv, func(r *http.Request) {
+		if r.Method == http.MethodPost {
+			written.Store(true)
+		} else if r.Method == http.MethodGet && written.Load() {
+			r.URL.Path = strings.Replace(r.URL.Path, "example.com", "example.org", 1)
+		}
+	})
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+
+	_, err := provider.AppendRecords(context.Background(), "example.com.", []libdns.Record{
+		libdns.Address{Name: "www", IP: netip.MustParseAddr("192.0.2.1")},
+	})
+	var apiErr *mythicbeasts.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, mythicbeasts.ErrZoneNotFound) {
+		t.Errorf("AppendRecords returned %v, want the read back's *APIError", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "API added 0 records, expected 1") {
+		t.Errorf("error %v does not report the mismatch", err)
+	}
+}
@@ -0,0 +1,94 @@
+package mythicbeasts
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every request a Provider makes.
+type rateLimiter struct {
+	rate        float64 // Tokens added per second
+	burst       float64 // Capacity of the bucket
+	configBurst int     // The burst asked for, which may have been defaulted
+
+	mutex  sync.Mutex // Guards tokens and last
+	tokens float64    // Negative when requests are waiting
+	last   time.Time  // When tokens was last brought up to date
+}
+
+// newRateLimiter returns a limiter allowing rate requests per second on
+// average and up to burst at once, with a full bucket.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	capacity := float64(burst)
+	if burst < 1 {
+		capacity = math.Max(1, math.Ceil(rate))
+	}
+	return &rateLimiter{
+		rate:        rate,
+		burst:       capacity,
+		configBurst: burst,
+		tokens:      capacity,
+		last:        time.Now(),
+	}
+}
+
+// wait blocks until a request may be made or ctx is done. A request which
+// gives up waiting returns its token for others to use.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mutex.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	err := ctx.Err()
+	if deadline, ok := ctx.Deadline(); err == nil && ok && now.Add(delay).After(deadline) {
+		err = fmt.Errorf("rate limit wait of %s would exceed context deadline: %w", delay, context.DeadlineExceeded)
+	}
+	if err == nil {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	l.mutex.Lock()
+	l.tokens++
+	l.mutex.Unlock()
+	return err
+}
+
+// waitRateLimit blocks until the Provider's rate limit allows another
+// request, or ctx is done. It returns immediately if RateLimit is not set.
+func (p *Provider) waitRateLimit(ctx context.Context) error {
+	if p.RateLimit <= 0 {
+		return nil
+	}
+
+	p.mutex.Lock()
+	if p.limiter == nil || p.limiter.rate != p.RateLimit || p.limiter.configBurst != p.RateBurst {
+		p.limiter = newRateLimiter(p.RateLimit, p.RateBurst)
+	}
+	limiter := p.limiter
+	p.mutex.Unlock()
+
+	return limiter.wait(ctx)
+}
@@ -0,0 +1,105 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestRateLimit(t *testing.T) {
+	get := func(p *mythicbeasts.Provider) error {
+		_, err := p.GetRecordsFiltered(context.Background(), "example.com.", "www", "A")
+		return err
+	}
+
+	tests := []struct {
+		name       string
+		rate       float64
+		burst      int
+		concurrent bool
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		// After the first calls empty the bucket, 10 requests at 20 per
+		// second take half a second, however they are made.
+		{name: "sequential", rate: 20, burst: 1, minElapsed: 400 * time.Millisecond},
+		{name: "concurrent", rate: 20, burst: 1, concurrent: true, minElapsed: 400 * time.Millisecond},
+		{name: "unlimited", concurrent: true, maxElapsed: 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+
+			provider := newProvider(srv)
+			provider.RateLimit = tt.rate
+			provider.RateBurst = tt.burst
+			if err := get(provider); err != nil {
+				t.Fatalf("GetRecordsFiltered: %v", err)
+			}
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				call := func() {
+					if err := get(provider); err != nil {
+						t.Errorf("GetRecordsFiltered: %v", err)
+					}
+				}
+				if !tt.concurrent {
+					call()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					call()
+				}()
+			}
+			wg.Wait()
+			elapsed := time.Since(start)
+
+			if elapsed < tt.minElapsed {
+				t.Errorf("took %v, want at least %v", elapsed, tt.minElapsed)
+			}
+			if tt.maxElapsed != 0 && elapsed > tt.maxElapsed {
+				t.Errorf("took %v, want at most %v", elapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+func TestRateLimitGivesUpAtDeadline(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	provider.RateLimit = 0.5
+	provider.RateBurst = 3 // Enough for the login and first call
+	if _, err := provider.GetRecords(context.Background(), "example.com."); err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	requests := srv.Requests()
+
+	// The next request would have to wait two seconds, beyond the deadline,
+	// so it fails at once without being made.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := provider.GetRecords(ctx, "example.com."); err == nil {
+		t.Errorf("GetRecords succeeded, want a rate limit error")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("took %v to give up, want no wait", elapsed)
+	}
+	if n := srv.Requests() - requests; n != 0 {
+		t.Errorf("made %d requests, want none", n)
+	}
+}
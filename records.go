@@ -0,0 +1,162 @@
+package mythicbeasts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// ANAME is an ANAME record, which Mythic Beasts answers with the addresses
+// of Target. Unlike a CNAME it can be used at the zone apex.
+type ANAME struct {
+	Name string
+	TTL  time.Duration
+
+	// Target is the fully qualified domain name whose addresses are served.
+	// A trailing dot is added if missing.
+	Target string
+
+	// ProviderData holds the RecordSource of records returned by this
+	// package.
+	ProviderData any
+}
+
+// RR returns the generic form of the record.
+func (a ANAME) RR() libdns.RR {
+	return libdns.RR{
+		Name: aliasName(a.Name),
+		TTL:  a.TTL,
+		Type: "ANAME",
+		Data: aliasTarget(a.Target),
+	}
+}
+
+// DNAME is a DNAME record, which redirects every name below Name to the
+// same name below Target (RFC 6672).
+type DNAME struct {
+	Name string
+	TTL  time.Duration
+
+	// Target is the fully qualified domain name of the tree which names
+	// are redirected to. A trailing dot is added if missing.
+	Target string
+
+	// ProviderData holds the RecordSource of records returned by this
+	// package.
+	ProviderData any
+}
+
+// RR returns the generic form of the record.
+func (d DNAME) RR() libdns.RR {
+	return libdns.RR{
+		Name: aliasName(d.Name),
+		TTL:  d.TTL,
+		Type: "DNAME",
+		Data: aliasTarget(d.Target),
+	}
+}
+
+// SSHFP is an SSHFP record, which publishes the fingerprint of an SSH host
+// key (RFC 4255). libdns has no type for it, but records read from a zone
+// are returned as SSHFP so that they can carry their RecordSource.
+type SSHFP struct {
+	Name            string
+	TTL             time.Duration
+	Algorithm       uint8
+	FingerprintType uint8
+
+	// Fingerprint is the fingerprint of the key in hex.
+	Fingerprint string
+
+	// ProviderData holds the RecordSource of records returned by this
+	// package.
+	ProviderData any
+}
+
+// RR returns the generic form of the record.
+func (s SSHFP) RR() libdns.RR {
+	return libdns.RR{
+		Name: s.Name,
+		TTL:  s.TTL,
+		Type: "SSHFP",
+		Data: fmt.Sprintf("%d %d %s", s.Algorithm, s.FingerprintType, s.Fingerprint),
+	}
+}
+
+// TLSA is a TLSA record, which associates a TLS certificate or public key
+// with a service (RFC 6698). Like SSHFP, records read from a zone are
+// returned as TLSA so that they can carry their RecordSource.
+type TLSA struct {
+	Name         string
+	TTL          time.Duration
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+
+	// AssociationData is the certificate or key, or its digest, in hex.
+	AssociationData string
+
+	// ProviderData holds the RecordSource of records returned by this
+	// package.
+	ProviderData any
+}
+
+// RR returns the generic form of the record.
+func (t TLSA) RR() libdns.RR {
+	return libdns.RR{
+		Name: t.Name,
+		TTL:  t.TTL,
+		Type: "TLSA",
+		Data: fmt.Sprintf("%d %d %d %s", t.Usage, t.Selector, t.MatchingType, t.AssociationData),
+	}
+}
+
+// aliasName normalises the name of an ANAME or DNAME record, which is
+// relative to the zone with "@" for the apex.
+func aliasName(name string) string {
+	return normalizeName(strings.TrimSpace(name))
+}
+
+// aliasTarget normalises the target of an ANAME or DNAME record to a fully
+// qualified domain name.
+func aliasTarget(target string) string {
+	target = strings.TrimSpace(target)
+	if target == "" || target == "@" || strings.HasSuffix(target, ".") {
+		return target
+	}
+	return target + "."
+}
+
+// parseRR is like rr.Parse, but also parses the ANAME, DNAME, SSHFP and
+// TLSA records defined by this package.
+func parseRR(rr libdns.RR) (libdns.Record, error) {
+	switch rr.Type {
+	case "ANAME":
+		return ANAME{Name: aliasName(rr.Name), TTL: rr.TTL, Target: aliasTarget(rr.Data)}, nil
+	case "DNAME":
+		return DNAME{Name: aliasName(rr.Name), TTL: rr.TTL, Target: aliasTarget(rr.Data)}, nil
+	case "SSHFP":
+		fields, value, err := splitNumericFields(rr.Data, "algorithm", "type")
+		if err != nil {
+			return nil, fmt.Errorf("SSHFP %w", err)
+		}
+		return SSHFP{Name: rr.Name, TTL: rr.TTL, Algorithm: fields[0], FingerprintType: fields[1], Fingerprint: value}, nil
+	case "TLSA":
+		fields, value, err := splitNumericFields(rr.Data, "usage", "selector", "matching type")
+		if err != nil {
+			return nil, fmt.Errorf("TLSA %w", err)
+		}
+		return TLSA{Name: rr.Name, TTL: rr.TTL, Usage: fields[0], Selector: fields[1], MatchingType: fields[2], AssociationData: value}, nil
+	}
+	return rr.Parse()
+}
+
+// Interface guards
+var (
+	_ libdns.Record = ANAME{}
+	_ libdns.Record = DNAME{}
+	_ libdns.Record = SSHFP{}
+	_ libdns.Record = TLSA{}
+)
@@ -0,0 +1,115 @@
+package mythicbeasts
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+	defaultRetryAfter   = 30 * time.Second
+)
+
+// maxRetries returns the number of times an idempotent request is retried.
+func (p *Provider) maxRetries() int {
+	if p.MaxRetries < 0 {
+		return 0
+	}
+	if p.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	return p.MaxRetries
+}
+
+// maxRetryAfter returns the longest Retry-After which is waited for; beyond
+// this the error is returned to the caller instead.
+func (p *Provider) maxRetryAfter() time.Duration {
+	if p.MaxRetryAfter > 0 {
+		return p.MaxRetryAfter
+	}
+	return defaultRetryAfter
+}
+
+// retryDelay returns how long to wait before retry number attempt+1. A
+// Retry-After from the server takes precedence over exponential backoff,
+// which is jittered so that concurrent clients do not retry in lockstep.
+func (p *Provider) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	backoff := p.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 0; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isIdempotent reports whether a request with method may safely be repeated.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// isRetryable reports whether a request which failed with status, or 0 for a
+// connection error, is worth retrying.
+func isRetryable(ctx context.Context, status int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 if the header is absent or invalid.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleepContext waits for d, returning false without waiting if that would
+// exceed the context deadline, or if the context is cancelled while waiting.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(d).After(deadline) {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
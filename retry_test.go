@@ -0,0 +1,153 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestRetries(t *testing.T) {
+	get := func(ctx context.Context, p *mythicbeasts.Provider) error {
+		_, err := p.GetRecordsFiltered(ctx, "example.com.", "www", "A")
+		return err
+	}
+	post := func(ctx context.Context, p *mythicbeasts.Provider) error {
+		_, err := p.AppendRecords(ctx, "example.com.", []libdns.Record{libdns.TXT{Name: "www", Text: "x"}})
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		fail         func(*mythicbeaststest.Server)
+		maxRetries   int
+		maxWait      time.Duration
+		timeout      time.Duration
+		call         func(context.Context, *mythicbeasts.Provider) error
+		wantErr      error
+		wantRequests int
+		minElapsed   time.Duration
+		maxElapsed   time.Duration
+	}{
+		{
+			name:         "GET retried after 503",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNext(2, http.StatusServiceUnavailable) },
+			call:         get,
+			wantRequests: 3,
+		},
+		{
+			name:         "GET gives up after MaxRetries",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNext(3, http.StatusBadGateway) },
+			maxRetries:   1,
+			call:         get,
+			wantErr:      &mythicbeasts.APIError{},
+			wantRequests: 2,
+		},
+		{
+			name:         "retries disabled",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNext(1, http.StatusInternalServerError) },
+			maxRetries:   -1,
+			call:         get,
+			wantErr:      &mythicbeasts.APIError{},
+			wantRequests: 1,
+		},
+		{
+			name:         "POST not retried",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNext(1, http.StatusServiceUnavailable) },
+			call:         post,
+			wantErr:      &mythicbeasts.APIError{},
+			wantRequests: 1,
+		},
+		{
+			name:         "404 not retried",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNext(1, http.StatusNotFound) },
+			call:         get,
+			wantErr:      mythicbeasts.ErrZoneNotFound,
+			wantRequests: 1,
+		},
+		{
+			name:         "429 waits for Retry-After",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNextRetryAfter(1, http.StatusTooManyRequests, 1) },
+			call:         get,
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "Retry-After beyond the deadline",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNextRetryAfter(1, http.StatusTooManyRequests, 1) },
+			timeout:      200 * time.Millisecond,
+			call:         get,
+			wantErr:      mythicbeasts.ErrRateLimited,
+			wantRequests: 1,
+			maxElapsed:   100 * time.Millisecond,
+		},
+		{
+			name:         "Retry-After beyond MaxRetryAfter",
+			fail:         func(s *mythicbeaststest.Server) { s.FailNextRetryAfter(1, http.StatusTooManyRequests, 1) },
+			maxWait:      500 * time.Millisecond,
+			call:         get,
+			wantErr:      mythicbeasts.ErrRateLimited,
+			wantRequests: 1,
+			maxElapsed:   100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+
+			provider := newProvider(srv)
+			provider.MaxRetries = tt.maxRetries
+			provider.MaxRetryAfter = tt.maxWait
+
+			// Log in and resolve the zone before counting requests.
+			if _, err := provider.GetRecords(context.Background(), "example.com."); err != nil {
+				t.Fatalf("GetRecords: %v", err)
+			}
+			before := srv.Requests()
+			tt.fail(srv)
+
+			ctx := context.Background()
+			if tt.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := tt.call(ctx, provider)
+			elapsed := time.Since(start)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("error %v, want none", err)
+				}
+			case *mythicbeasts.APIError:
+				if !errors.As(err, &want) {
+					t.Errorf("error %v, want an *APIError", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("error %v, want %v", err, want)
+				}
+			}
+			if requests := srv.Requests() - before; requests != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", requests, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("took %v, want at least %v", elapsed, tt.minElapsed)
+			}
+			if tt.maxElapsed != 0 && elapsed > tt.maxElapsed {
+				t.Errorf("took %v, want at most %v", elapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package mythicbeasts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Token is a bearer token for the DNS API.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// valid reports whether the token can still be used, allowing 30 seconds
+// for requests made with it to complete.
+func (t Token) valid() bool {
+	return t.AccessToken != "" && time.Now().Add(30*time.Second).Before(t.ExpiresAt)
+}
+
+// TokenStore holds bearer tokens keyed by API key ID, so that they can be
+// reused by other Providers or processes until they expire rather than
+// logging in again. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// LoadToken returns the token stored for keyID, and false if there is
+	// none.
+	LoadToken(ctx context.Context, keyID string) (Token, bool, error)
+
+	// StoreToken stores token for keyID, replacing any existing token.
+	StoreToken(ctx context.Context, keyID string, token Token) error
+
+	// DeleteToken removes any token stored for keyID.
+	DeleteToken(ctx context.Context, keyID string) error
+}
+
+// TokenLocker may be implemented by a TokenStore to serialise logins for a
+// key ID. A Provider holds the lock from checking the store for a token
+// until it has stored a new one, so that while one Provider or process logs
+// in, the others wait and then use its token rather than logging in too.
+type TokenLocker interface {
+	// LockToken waits until the caller holds the login lock for keyID, or
+	// ctx is done, and returns the function which releases it.
+	LockToken(ctx context.Context, keyID string) (unlock func(), err error)
+}
+
+// MemoryTokenStore is a TokenStore which holds tokens in memory. It can be
+// shared between Providers in the same process. The zero value is ready to
+// use.
+type MemoryTokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string]Token
+	locks  map[string]chan struct{} // Login locks, held by a send
+}
+
+func (s *MemoryTokenStore) LoadToken(_ context.Context, keyID string) (Token, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[keyID]
+	return token, ok, nil
+}
+
+func (s *MemoryTokenStore) StoreToken(_ context.Context, keyID string, token Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.tokens == nil {
+		s.tokens = make(map[string]Token)
+	}
+	s.tokens[keyID] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteToken(_ context.Context, keyID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.tokens, keyID)
+	return nil
+}
+
+func (s *MemoryTokenStore) LockToken(ctx context.Context, keyID string) (func(), error) {
+	s.mutex.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]chan struct{})
+	}
+	lock, ok := s.locks[keyID]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.locks[keyID] = lock
+	}
+	s.mutex.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("MemoryTokenStore: waiting for lock: %w", ctx.Err())
+	}
+}
+
+// FileTokenStore is a TokenStore which keeps each token in a file in Dir,
+// so that tokens can be shared between processes such as cron jobs. Files
+// are readable only by their owner and are named by a hash of the key ID.
+// Writes take a lock file and replace the token file atomically, so readers
+// never see a partial token. Logins take a separate lock file, so that
+// processes sharing Dir log in one at a time; see TokenLocker.
+type FileTokenStore struct {
+	Dir string
+
+	// StaleLockAge is the age after which a lock file left behind by a
+	// crashed process is removed. It should exceed the time a login takes.
+	// Defaults to 30 seconds.
+	StaleLockAge time.Duration
+}
+
+// path returns the token file for keyID.
+func (s *FileTokenStore) path(keyID string) string {
+	sum := sha256.Sum256([]byte(keyID))
+	return filepath.Join(s.Dir, "mythicbeasts-"+hex.EncodeToString(sum[:16])+".json")
+}
+
+func (s *FileTokenStore) LoadToken(_ context.Context, keyID string) (Token, bool, error) {
+	data, err := os.ReadFile(s.path(keyID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Token{}, false, nil
+	}
+	if err != nil {
+		return Token{}, false, fmt.Errorf("FileTokenStore: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return Token{}, false, fmt.Errorf("FileTokenStore: %s: %w", s.path(keyID), err)
+	}
+	return token, true, nil
+}
+
+func (s *FileTokenStore) StoreToken(ctx context.Context, keyID string, token Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+
+	path := s.path(keyID)
+	unlock, err := s.lock(ctx, path+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tmp, err := os.CreateTemp(s.Dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp creates files with mode 0600, but be explicit as the file
+	// holds a credential.
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+	return nil
+}
+
+func (s *FileTokenStore) DeleteToken(ctx context.Context, keyID string) error {
+	path := s.path(keyID)
+	unlock, err := s.lock(ctx, path+".lock")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("FileTokenStore: %w", err)
+	}
+	return nil
+}
+
+func (s *FileTokenStore) LockToken(ctx context.Context, keyID string) (func(), error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("FileTokenStore: %w", err)
+	}
+	return s.lock(ctx, s.path(keyID)+".login.lock")
+}
+
+// lock takes an exclusive lock by creating the file lockPath, waiting for
+// any other holder until ctx is done.
+func (s *FileTokenStore) lock(ctx context.Context, lockPath string) (func(), error) {
+	staleAge := s.StaleLockAge
+	if staleAge <= 0 {
+		staleAge = 30 * time.Second
+	}
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("FileTokenStore: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleAge {
+			os.Remove(lockPath)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("FileTokenStore: waiting for lock: %w", ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Interface guards
+var (
+	_ TokenStore = (*MemoryTokenStore)(nil)
+	_ TokenStore = (*FileTokenStore)(nil)
+
+	_ TokenLocker = (*MemoryTokenStore)(nil)
+	_ TokenLocker = (*FileTokenStore)(nil)
+)
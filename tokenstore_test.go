@@ -0,0 +1,85 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestSharedTokenStoreLogsInOnce(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T) mythicbeasts.TokenStore
+	}{
+		{"memory", func(*testing.T) mythicbeasts.TokenStore { return &mythicbeasts.MemoryTokenStore{} }},
+		{"file", func(t *testing.T) mythicbeasts.TokenStore { return &mythicbeasts.FileTokenStore{Dir: t.TempDir()} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+
+			// Each Provider stands in for a separate process sharing the
+			// store, such as a cron job.
+			store := tt.store(t)
+			var wg sync.WaitGroup
+			errs := make([]error, 8)
+			for i := range errs {
+				provider := newProvider(srv)
+				provider.TokenStore = store
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, errs[i] = provider.GetRecords(context.Background(), "example.com.")
+				}(i)
+			}
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Errorf("GetRecords %d: %v", i, err)
+				}
+			}
+			if logins := srv.Logins(); logins != 1 {
+				t.Errorf("logged in %d times, want 1", logins)
+			}
+		})
+	}
+}
+
+func TestFileTokenStorePermissions(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	dir := filepath.Join(t.TempDir(), "tokens")
+	provider := newProvider(srv)
+	provider.TokenStore = &mythicbeasts.FileTokenStore{Dir: dir}
+	if _, err := provider.GetRecords(context.Background(), "example.com."); err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("token files %v, %v; want one", files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("token file mode %v, want 0600", mode)
+	}
+
+	// Lock files must not be left behind.
+	if locks, _ := filepath.Glob(filepath.Join(dir, "*.lock")); len(locks) != 0 {
+		t.Errorf("lock files %v left behind", locks)
+	}
+}
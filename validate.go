@@ -0,0 +1,336 @@
+package mythicbeasts
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// maxTTL is the largest TTL allowed by RFC 2181, in seconds.
+const maxTTL = 1<<31 - 1
+
+// Validate checks every record before it is sent to the API, so that bad
+// input is reported without any network traffic. It returns a
+// *ValidationError listing each invalid record by its index.
+func (mrl *mythicRecords) Validate() error {
+	var invalid []InvalidRecord
+	for i, r := range mrl.Records {
+		err := r.validate()
+		if err != nil {
+			invalid = append(invalid, InvalidRecord{Index: i, Name: r.GetName(), Type: r.GetType(), Err: err})
+		}
+	}
+
+	if len(invalid) > 0 {
+		return &ValidationError{Records: invalid}
+	}
+	return nil
+}
+
+// validateRecord converts and validates a single record, as FromLibdns does.
+func validateRecord(record libdns.Record) error {
+	mr, err := fromLibdns(record)
+	if err != nil {
+		return err
+	}
+	return mr.validate()
+}
+
+// invalidRecord stands in for a record which could not be converted for the
+// API, so that Validate reports it alongside the others.
+type invalidRecord struct {
+	mythicRecord
+	err error
+}
+
+func (r invalidRecord) withName(name string) mythicRecordType {
+	r.Name = name
+	return r
+}
+func (r invalidRecord) GetLibdnsRecord() (libdns.Record, error) {
+	return nil, r.err
+}
+func (r invalidRecord) validate() error {
+	return r.err
+}
+
+func (r mythicRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+
+	switch r.Type {
+	case "A":
+		ip, err := netip.ParseAddr(r.Value)
+		if err != nil || !ip.Is4() {
+			return fmt.Errorf("A data %q is not an IPv4 address", r.Value)
+		}
+	case "AAAA":
+		ip, err := netip.ParseAddr(r.Value)
+		if err != nil || !ip.Is6() || ip.Is4In6() {
+			return fmt.Errorf("AAAA data %q is not an IPv6 address", r.Value)
+		}
+	case "CNAME", "NS", "PTR", "ANAME", "DNAME":
+		err := validateHostname(r.Value, false)
+		if err != nil {
+			return fmt.Errorf("%s target: %w", r.Type, err)
+		}
+	}
+	return nil
+}
+
+// validateCommon checks the type, name and TTL shared by all records.
+func (r mythicRecord) validateCommon() error {
+	if r.Type == "" {
+		return fmt.Errorf("record has no type")
+	}
+	err := validateHostname(r.Name, true)
+	if err != nil {
+		return fmt.Errorf("name: %w", err)
+	}
+	if r.TTL < 0 || r.TTL > maxTTL {
+		return fmt.Errorf("TTL %ds is not between 0 and %ds", r.TTL, maxTTL)
+	}
+	return nil
+}
+
+func (r mythicMxRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	err = validateTarget(r.Value)
+	if err != nil {
+		return fmt.Errorf("MX target: %w", err)
+	}
+	return nil
+}
+
+func (r mythicCaaRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	if r.Tag == "" || len(r.Tag) > 15 || strings.IndexFunc(r.Tag, func(c rune) bool { return !isAlnum(c) }) >= 0 {
+		return fmt.Errorf("CAA tag %q must be 1 to 15 letters or digits", r.Tag)
+	}
+	return nil
+}
+
+func (r mythicSrvRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	labels := strings.SplitN(r.Name, ".", 3)
+	if len(labels) < 2 || len(labels[0]) < 2 || len(labels[1]) < 2 || labels[0][0] != '_' || labels[1][0] != '_' {
+		return fmt.Errorf("SRV name %q is not in the form _service._proto[.name]", r.Name)
+	}
+	err = validateTarget(r.Value)
+	if err != nil {
+		return fmt.Errorf("SRV target: %w", err)
+	}
+	return nil
+}
+
+// sshfpLengths are the fingerprint lengths in bytes of the SSHFP types.
+var sshfpLengths = map[uint8]int{1: 20, 2: 32}
+
+func (r mythicSshfpRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	if r.Algorithm == 0 {
+		return fmt.Errorf("SSHFP algorithm 0 is reserved")
+	}
+	if r.SshfpType == 0 {
+		return fmt.Errorf("SSHFP type 0 is reserved")
+	}
+	err = validateHex(r.Value, sshfpLengths[r.SshfpType])
+	if err != nil {
+		return fmt.Errorf("SSHFP fingerprint: %w", err)
+	}
+	return nil
+}
+
+// tlsaLengths are the lengths in bytes of the TLSA matching types' digests.
+var tlsaLengths = map[uint8]int{1: 32, 2: 64}
+
+func (r mythicTlsaRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	if r.Usage > 3 {
+		return fmt.Errorf("TLSA usage %d is not from 0 to 3", r.Usage)
+	}
+	if r.Selector > 1 {
+		return fmt.Errorf("TLSA selector %d is not 0 or 1", r.Selector)
+	}
+	if r.Matching > 2 {
+		return fmt.Errorf("TLSA matching type %d is not from 0 to 2", r.Matching)
+	}
+	err = validateHex(r.Value, tlsaLengths[r.Matching])
+	if err != nil {
+		return fmt.Errorf("TLSA data: %w", err)
+	}
+	return nil
+}
+
+func (r mythicSvcbRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	err = validateTarget(r.Value)
+	if err != nil {
+		return fmt.Errorf("%s target: %w", r.Type, err)
+	}
+	if r.Priority == 0 {
+		if r.Params != "" {
+			return fmt.Errorf("%s record in AliasMode (priority 0) cannot have SvcParams", r.Type)
+		}
+		return nil
+	}
+
+	params, err := libdns.ParseSvcParams(r.Params)
+	if err != nil {
+		return fmt.Errorf("%s SvcParams: %w", r.Type, err)
+	}
+	for key, values := range params {
+		err := validateSvcParam(key, values)
+		if err != nil {
+			return fmt.Errorf("%s SvcParam %s: %w", r.Type, key, err)
+		}
+	}
+	return nil
+}
+
+// validateSvcParam checks the value of a SvcParam with a registered key.
+// Other keys must be in the generic "keyNNNNN" form.
+func validateSvcParam(key string, values []string) error {
+	if svcParamKeyNumber(key) < 0 {
+		return fmt.Errorf("unknown key")
+	}
+
+	switch key {
+	case "no-default-alpn":
+		if len(values) > 1 || len(values) == 1 && values[0] != "" {
+			return fmt.Errorf("takes no value")
+		}
+	case "mandatory", "alpn":
+		for _, value := range values {
+			if value == "" {
+				return fmt.Errorf("empty value in list")
+			}
+		}
+	case "port":
+		if len(values) != 1 {
+			return fmt.Errorf("takes a single port")
+		}
+		if _, err := strconv.ParseUint(values[0], 10, 16); err != nil {
+			return fmt.Errorf("%q is not a port number", values[0])
+		}
+	case "ipv4hint", "ipv6hint":
+		for _, value := range values {
+			ip, err := netip.ParseAddr(value)
+			if err != nil || ip.Is4() != (key == "ipv4hint") || ip.Is4In6() {
+				return fmt.Errorf("%q is not an IPv%s address", value, key[3:4])
+			}
+		}
+	case "ech":
+		if len(values) != 1 {
+			return fmt.Errorf("takes a single ECHConfigList")
+		}
+		if _, err := base64.StdEncoding.DecodeString(values[0]); err != nil {
+			return fmt.Errorf("%q is not base64", values[0])
+		}
+	}
+	return nil
+}
+
+// dsLengths are the digest lengths in bytes of the DS digest types: SHA-1,
+// SHA-256, GOST R 34.11-94 and SHA-384.
+var dsLengths = map[uint8]int{1: 20, 2: 32, 3: 32, 4: 48}
+
+func (r mythicDsRecord) validate() error {
+	err := r.validateCommon()
+	if err != nil {
+		return err
+	}
+	if r.Algorithm == 0 {
+		return fmt.Errorf("DS algorithm 0 is reserved")
+	}
+	if r.DigestType == 0 {
+		return fmt.Errorf("DS digest type 0 is reserved")
+	}
+	err = validateHex(r.Value, dsLengths[r.DigestType])
+	if err != nil {
+		return fmt.Errorf("DS digest: %w", err)
+	}
+	return nil
+}
+
+// validateHostname checks that name is a valid host name, either relative or
+// fully qualified, or "@" for the zone apex. Underscores are allowed, as used
+// by service labels, and if wildcard is true so is a leading "*" label.
+func validateHostname(name string, wildcard bool) error {
+	if name == "@" {
+		return nil
+	}
+	fqdn := strings.TrimSuffix(name, ".")
+	if fqdn == "" {
+		return fmt.Errorf("empty host name")
+	}
+	if len(fqdn) > 253 {
+		return fmt.Errorf("host name %q is longer than 253 characters", name)
+	}
+
+	for i, label := range strings.Split(fqdn, ".") {
+		if label == "*" && wildcard && i == 0 {
+			continue
+		}
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("host name %q has a label which is empty or longer than 63 characters", name)
+		}
+		for _, c := range label {
+			if !isAlnum(c) && c != '-' && c != '_' {
+				return fmt.Errorf("host name %q contains invalid character %q", name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTarget checks the target of an MX or SRV record, which may be "."
+// to say that there is no service.
+func validateTarget(target string) error {
+	if target == "." {
+		return nil
+	}
+	return validateHostname(target, false)
+}
+
+// validateHex checks that value is non-empty hex, and if length is not zero
+// that it encodes that many bytes.
+func validateHex(value string, length int) error {
+	data, err := hex.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return fmt.Errorf("%q is not hex", value)
+	}
+	if length != 0 && len(data) != length {
+		return fmt.Errorf("%q is %d bytes rather than %d", value, len(data), length)
+	}
+	return nil
+}
+
+func isAlnum(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,91 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestValidationRejectsBeforeSending(t *testing.T) {
+	sshfp := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name    string
+		record  libdns.Record
+		wantErr string
+	}{
+		{"A with IPv6", libdns.RR{Name: "www", Type: "A", Data: "2001:db8::1"}, "not an IPv4 address"},
+		{"bad name", libdns.TXT{Name: "bad name", Text: "x"}, "invalid character"},
+		{"negative TTL", libdns.TXT{Name: "www", TTL: -time.Second, Text: "x"}, "TTL"},
+		{"CNAME target", libdns.CNAME{Name: "www", Target: "a..b."}, "CNAME target"},
+		{"MX target", libdns.MX{Name: "@", Preference: 10, Target: "mail server"}, "MX target"},
+		{"CAA tag", libdns.CAA{Name: "@", Tag: "is-sue", Value: "letsencrypt.org"}, "CAA tag"},
+		{"SRV name", libdns.RR{Name: "sip", Type: "SRV", Data: "10 5 5060 sip.example.com."}, "SRV"},
+		{"SSHFP length", libdns.RR{Name: "host", Type: "SSHFP", Data: "1 1 " + sshfp}, "rather than 20"},
+		{"SSHFP fields", libdns.RR{Name: "host", Type: "SSHFP", Data: sshfp}, "numeric fields"},
+		{"TLSA usage", libdns.RR{Name: "_443._tcp", Type: "TLSA", Data: "4 1 1 " + sshfp}, "TLSA usage"},
+		{"DS digest", libdns.RR{Name: "child", Type: "DS", Data: "12345 13 2 abcd"}, "rather than 32"},
+		{"SVCB AliasMode params", libdns.ServiceBinding{Name: "@", Scheme: "https", Priority: 0, Target: "cdn.example.net.", Params: libdns.SvcParams{"alpn": {"h2"}}}, "AliasMode"},
+		{"SVCB port", libdns.ServiceBinding{Name: "@", Scheme: "https", Priority: 1, Target: ".", Params: libdns.SvcParams{"port": {"http"}}}, "not a port number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com")
+
+			valid := libdns.Address{Name: "www", IP: netip.MustParseAddr("192.0.2.1")}
+			_, err := newProvider(srv).AppendRecords(context.Background(), "example.com.", []libdns.Record{valid, tt.record})
+
+			var validationErr *mythicbeasts.ValidationError
+			if !errors.As(err, &validationErr) || !errors.Is(err, mythicbeasts.ErrValidation) {
+				t.Fatalf("AppendRecords returned %v, want a *ValidationError", err)
+			}
+			if len(validationErr.Records) != 1 || validationErr.Records[0].Index != 1 {
+				t.Errorf("invalid records %+v, want only index 1", validationErr.Records)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantErr)
+			}
+			if srv.Logins() != 0 || srv.Requests() != 0 {
+				t.Errorf("made %d logins and %d requests, want none", srv.Logins(), srv.Requests())
+			}
+		})
+	}
+}
+
+func TestEmptyNameIsApex(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	record := []libdns.Record{libdns.TXT{Text: "v=spf1 -all"}}
+
+	added, err := provider.AppendRecords(context.Background(), "example.com.", record)
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if len(added) != 1 || added[0].RR().Name != "@" {
+		t.Errorf("added %+v, want a record at @", added)
+	}
+	if records := srv.Records("example.com"); len(records) != 1 || records[0].Host != "@" {
+		t.Errorf("zone holds %+v, want a record at @", records)
+	}
+
+	deleted, err := provider.DeleteRecords(context.Background(), "example.com.", record)
+	if err != nil || len(deleted) != 1 {
+		t.Errorf("DeleteRecords returned %d records, %v; want 1", len(deleted), err)
+	}
+	if records := srv.Records("example.com"); len(records) != 0 {
+		t.Errorf("zone holds %+v after deleting, want nothing", records)
+	}
+}
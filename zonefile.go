@@ -0,0 +1,683 @@
+package mythicbeasts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// ExportZone writes the records in zone to w as an RFC 1035 zone file, with
+// $ORIGIN and $TTL directives and an explicit TTL on every record. Records
+// are written in canonical name order, then by type and data, so that
+// exports of an unchanged zone are identical. The records exported are
+// those returned by GetRecords.
+func (p *Provider) ExportZone(ctx context.Context, zone string, w io.Writer) error {
+	records, err := p.GetRecords(ctx, zone)
+	if err != nil {
+		return fmt.Errorf("ExportZone: %w", err)
+	}
+
+	err = writeZoneFile(w, p.unFQDN(zone)+".", records)
+	if err != nil {
+		return fmt.Errorf("ExportZone: %w", err)
+	}
+	return nil
+}
+
+// writeZoneFile renders records, named relative to origin, as a zone file.
+func writeZoneFile(w io.Writer, origin string, records []libdns.Record) error {
+	rrs := make([]libdns.RR, len(records))
+	for i, record := range records {
+		rrs[i] = recordRR(record)
+		rrs[i].Name = normalizeName(rrs[i].Name)
+	}
+
+	sort.SliceStable(rrs, func(i, j int) bool {
+		if c := compareNames(rrs[i].Name, rrs[j].Name); c != 0 {
+			return c < 0
+		}
+		if rrs[i].Type != rrs[j].Type {
+			return rrs[i].Type < rrs[j].Type
+		}
+		return rrs[i].Data < rrs[j].Data
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s\n", origin)
+	fmt.Fprintf(bw, "$TTL %d\n", commonTTL(rrs))
+	for _, rr := range rrs {
+		fmt.Fprintf(bw, "%s\t%d\tIN\t%s\t%s\n", rr.Name, int(rr.TTL/time.Second), rr.Type, zoneFileData(rr))
+	}
+	return bw.Flush()
+}
+
+// compareNames orders relative names canonically (RFC 4034 section 6.1),
+// comparing labels from the right, so the apex comes first and names sort
+// alongside their parents.
+func compareNames(a, b string) int {
+	labels := func(name string) []string {
+		if name == "@" {
+			return nil
+		}
+		parts := strings.Split(strings.ToLower(name), ".")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		return parts
+	}
+
+	la, lb := labels(a), labels(b)
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if c := strings.Compare(la[i], lb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// commonTTL returns the most frequent TTL in seconds, preferring the lowest
+// on a tie, or 3600 if there are no records.
+func commonTTL(rrs []libdns.RR) int {
+	counts := make(map[int]int)
+	for _, rr := range rrs {
+		counts[int(rr.TTL/time.Second)]++
+	}
+
+	best, bestCount := 3600, 0
+	for ttl, count := range counts {
+		if count > bestCount || (count == bestCount && ttl < best) {
+			best, bestCount = ttl, count
+		}
+	}
+	return best
+}
+
+// zoneFileData returns the data of rr in zone file presentation format,
+// quoting text where the format requires it.
+func zoneFileData(rr libdns.RR) string {
+	switch rr.Type {
+	case "TXT":
+		return quoteText(rr.Data)
+	case "CAA":
+		record, err := rr.Parse()
+		if caa, ok := record.(libdns.CAA); ok && err == nil {
+			return fmt.Sprintf("%d %s %s", caa.Flags, caa.Tag, quoteText(caa.Value))
+		}
+	}
+	return rr.Data
+}
+
+// quoteText renders text as one or more quoted character-strings of at most
+// 255 bytes each, escaping quotes, backslashes and non-printable bytes.
+func quoteText(text string) string {
+	if text == "" {
+		return `""`
+	}
+
+	var chunks []string
+	for len(text) > 0 {
+		n := len(text)
+		if n > 255 {
+			n = 255
+		}
+		chunks = append(chunks, quoteString(text[:n]))
+		text = text[n:]
+	}
+	return strings.Join(chunks, " ")
+}
+
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// ImportMode controls how ImportZone combines a zone file with the records
+// already in the zone.
+type ImportMode int
+
+const (
+	// ImportMerge adds the records in the zone file which are not already in
+	// the zone, leaving all existing records in place.
+	ImportMerge ImportMode = iota
+
+	// ImportReplace makes the zone's records match the zone file, replacing
+	// each name and type in the file and deleting user records for any other
+	// name and type. Template and generated records are left in place.
+	ImportReplace
+)
+
+// ImportZone reads a BIND-style zone file from r and writes its records to
+// zone, combining them with existing records according to mode. The SOA
+// record and NS records at the zone apex are skipped, as these are managed
+// by Mythic Beasts. The whole file is parsed before anything is written, and
+// if any line cannot be imported an *ImportError listing every such line is
+// returned. It returns the records written to the zone.
+func (p *Provider) ImportZone(ctx context.Context, zone string, r io.Reader, mode ImportMode) ([]libdns.Record, error) {
+	records, err := parseZoneFile(r, p.unFQDN(zone)+".")
+	if err != nil {
+		return nil, fmt.Errorf("ImportZone: %w", err)
+	}
+
+	switch mode {
+	case ImportMerge:
+		existing, err := p.GetRecords(ctx, zone)
+		if err != nil {
+			return nil, fmt.Errorf("ImportZone: %w", err)
+		}
+
+		var toAdd []libdns.Record
+		for _, record := range records {
+			rr := recordRR(record)
+			present := false
+			for _, e := range append(existing, toAdd...) {
+				if sameRecord(recordRR(e), rr) {
+					present = true
+					break
+				}
+			}
+			if !present {
+				toAdd = append(toAdd, record)
+			}
+		}
+		if len(toAdd) == 0 {
+			return nil, nil
+		}
+
+		added, err := p.AppendRecords(ctx, zone, toAdd)
+		if err != nil {
+			return nil, fmt.Errorf("ImportZone: %w", err)
+		}
+		return added, nil
+
+	case ImportReplace:
+		// Only user records are candidates for deletion. They are listed
+		// separately, as not every record carries its RecordSource.
+		userRecords, err := p.listRecords(ctx, "ImportZone", zone, recordQuery{
+			excludeTemplate:  true,
+			excludeGenerated: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ImportZone: %w", err)
+		}
+
+		inFile := make(map[string]bool)
+		for _, record := range records {
+			rr := recordRR(record)
+			inFile[strings.ToLower(normalizeName(rr.Name))+"|"+rr.Type] = true
+		}
+
+		var toDelete []libdns.Record
+		for _, e := range userRecords {
+			rr := recordRR(e)
+			name := strings.ToLower(normalizeName(rr.Name))
+			if inFile[name+"|"+rr.Type] || rr.Type == "SOA" || (rr.Type == "NS" && name == "@") {
+				continue
+			}
+			toDelete = append(toDelete, e)
+		}
+
+		var set []libdns.Record
+		if len(records) > 0 {
+			set, err = p.SetRecords(ctx, zone, records)
+			if err != nil {
+				return nil, fmt.Errorf("ImportZone: %w", err)
+			}
+		}
+
+		if len(toDelete) > 0 {
+			_, err = p.DeleteRecords(ctx, zone, toDelete)
+			if err != nil {
+				return set, fmt.Errorf("ImportZone: %w", err)
+			}
+		}
+		return set, nil
+
+	default:
+		return nil, fmt.Errorf("ImportZone: unknown import mode %d", mode)
+	}
+}
+
+// zoneToken is a word or quoted string in a zone file. A word may contain
+// quoted sections, as in a SvcParam such as alpn="h2,h3".
+type zoneToken struct {
+	text   string // The token with quotes removed and escapes decoded
+	raw    string // The token as written in the file
+	quoted bool   // The token starts with a quote
+}
+
+// zoneLine is an entry in a zone file, which may span several physical
+// lines within parentheses.
+type zoneLine struct {
+	number      int  // Line number on which the entry starts
+	inheritName bool // The entry starts with whitespace, so has no owner name
+	tokens      []zoneToken
+}
+
+// lexZoneFile splits a zone file into entries, dropping comments and blank
+// lines. Errors are reported against the line on which they occur.
+func lexZoneFile(r io.Reader) ([]zoneLine, []LineError, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		lines    []zoneLine
+		lineErrs []LineError
+		current  zoneLine
+		token    strings.Builder
+		raw      strings.Builder
+		inToken  bool
+		quoted   bool
+		inQuotes bool
+		depth    int
+		number   = 1
+		start    = true
+	)
+
+	endToken := func() {
+		if inToken {
+			current.tokens = append(current.tokens, zoneToken{text: token.String(), raw: raw.String(), quoted: quoted})
+			token.Reset()
+			raw.Reset()
+			inToken, quoted = false, false
+		}
+	}
+	endLine := func() {
+		endToken()
+		if len(current.tokens) > 0 {
+			lines = append(lines, current)
+		}
+		current = zoneLine{number: number + 1}
+		start = true
+	}
+
+	current.number = number
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if inQuotes {
+			raw.WriteByte(c)
+			switch c {
+			case '"':
+				inQuotes = false
+			case '\\':
+				b, n := decodeEscape(data[i+1:])
+				token.WriteByte(b)
+				raw.Write(data[i+1 : i+1+n])
+				i += n
+			case '\n':
+				token.WriteByte(c)
+				number++
+			default:
+				token.WriteByte(c)
+			}
+			continue
+		}
+
+		if start {
+			start = false
+			current.inheritName = c == ' ' || c == '\t'
+		}
+
+		switch c {
+		case ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case '\n':
+			if depth > 0 {
+				endToken()
+			} else {
+				endLine()
+			}
+			number++
+		case ' ', '\t', '\r':
+			endToken()
+		case '(':
+			endToken()
+			depth++
+		case ')':
+			endToken()
+			if depth == 0 {
+				lineErrs = append(lineErrs, LineError{Line: number, Err: fmt.Errorf("unbalanced ')'")})
+			} else {
+				depth--
+			}
+		case '"':
+			// A quoted section continues the current token, if any.
+			quoted = quoted || !inToken
+			inToken, inQuotes = true, true
+			raw.WriteByte(c)
+		case '\\':
+			b, n := decodeEscape(data[i+1:])
+			token.WriteByte(b)
+			raw.Write(data[i : i+1+n])
+			inToken = true
+			i += n
+		default:
+			token.WriteByte(c)
+			raw.WriteByte(c)
+			inToken = true
+		}
+	}
+
+	if inQuotes {
+		lineErrs = append(lineErrs, LineError{Line: current.number, Err: fmt.Errorf("unterminated quoted string")})
+		current.tokens, inToken = nil, false
+	} else if depth > 0 {
+		lineErrs = append(lineErrs, LineError{Line: current.number, Err: fmt.Errorf("unbalanced '('")})
+		current.tokens, inToken = nil, false
+	}
+	endLine()
+
+	return lines, lineErrs, nil
+}
+
+// decodeEscape decodes the escape sequence following a backslash, either
+// \DDD or \X, returning the byte and the number of input bytes consumed.
+func decodeEscape(rest []byte) (byte, int) {
+	if len(rest) >= 3 && isDigit(rest[0]) && isDigit(rest[1]) && isDigit(rest[2]) {
+		v := int(rest[0]-'0')*100 + int(rest[1]-'0')*10 + int(rest[2]-'0')
+		if v <= 255 {
+			return byte(v), 3
+		}
+	}
+	if len(rest) == 0 {
+		return '\\', 0
+	}
+	return rest[0], 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseTTL parses a TTL in seconds or using BIND's unit suffixes, such as
+// "1h30m".
+func parseTTL(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second, 'm': time.Minute, 'h': time.Hour,
+		'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour,
+	}
+
+	var total time.Duration
+	value, digits := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isDigit(c) {
+			value = value*10 + int(c-'0')
+			digits++
+			if value > 1<<31-1 {
+				return 0, false
+			}
+			continue
+		}
+		unit, ok := units[c|0x20]
+		if !ok || digits == 0 {
+			return 0, false
+		}
+		total += time.Duration(value) * unit
+		value, digits = 0, 0
+	}
+	if digits > 0 {
+		total += time.Duration(value) * time.Second
+	}
+	return total, true
+}
+
+// zoneParser converts zone file entries into records relative to zone.
+type zoneParser struct {
+	zone       string // FQDN of the zone being imported, lowercase
+	origin     string // Current $ORIGIN, lowercase FQDN
+	defaultTTL time.Duration
+	hasTTL     bool
+	lastName   string
+	lastTTL    time.Duration
+}
+
+// parseZoneFile parses a zone file for zone, returning an *ImportError if
+// any entry is invalid or unsupported.
+func parseZoneFile(r io.Reader, zone string) ([]libdns.Record, error) {
+	lines, lineErrs, err := lexZoneFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	zone = strings.ToLower(zone)
+	zp := &zoneParser{zone: zone, origin: zone}
+
+	var records []libdns.Record
+	for _, line := range lines {
+		record, err := zp.parseLine(line)
+		if err == nil && record != nil {
+			err = validateRecord(record)
+		}
+		if err != nil {
+			lineErrs = append(lineErrs, LineError{Line: line.number, Err: err})
+			continue
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+
+	if len(lineErrs) > 0 {
+		sort.SliceStable(lineErrs, func(i, j int) bool { return lineErrs[i].Line < lineErrs[j].Line })
+		return nil, &ImportError{Lines: lineErrs}
+	}
+	return records, nil
+}
+
+// absolute makes name a lowercase FQDN relative to the current origin.
+func (zp *zoneParser) absolute(name string) string {
+	if name == "@" {
+		return zp.origin
+	}
+	if strings.HasSuffix(name, ".") {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "." + zp.origin
+}
+
+// relative makes name relative to the zone being imported.
+func (zp *zoneParser) relative(name string) (string, error) {
+	abs := zp.absolute(name)
+	if abs == zp.zone {
+		return "@", nil
+	}
+	if strings.HasSuffix(abs, "."+zp.zone) {
+		return strings.TrimSuffix(abs, "."+zp.zone), nil
+	}
+	return "", fmt.Errorf("name %s is outside of zone %s", abs, zp.zone)
+}
+
+// parseLine parses a directive or record. It returns a nil record for
+// directives and for records which are skipped.
+func (zp *zoneParser) parseLine(line zoneLine) (libdns.Record, error) {
+	tokens := line.tokens
+
+	switch strings.ToUpper(tokens[0].text) {
+	case "$ORIGIN":
+		if len(tokens) != 2 || !strings.HasSuffix(tokens[1].text, ".") {
+			return nil, fmt.Errorf("$ORIGIN requires a single absolute name")
+		}
+		zp.origin = strings.ToLower(tokens[1].text)
+		return nil, nil
+	case "$TTL":
+		ttl, ok := time.Duration(0), len(tokens) == 2
+		if ok {
+			ttl, ok = parseTTL(tokens[1].text)
+		}
+		if !ok {
+			return nil, fmt.Errorf("$TTL requires a single TTL value")
+		}
+		zp.defaultTTL, zp.hasTTL = ttl, true
+		return nil, nil
+	case "$INCLUDE", "$GENERATE":
+		return nil, fmt.Errorf("%s is not supported", tokens[0].text)
+	}
+
+	var name string
+	if line.inheritName {
+		if zp.lastName == "" {
+			return nil, fmt.Errorf("record has no owner name")
+		}
+		name = zp.lastName
+	} else {
+		var err error
+		name, err = zp.relative(tokens[0].text)
+		if err != nil {
+			return nil, err
+		}
+		tokens = tokens[1:]
+	}
+	zp.lastName = name
+
+	ttl, hasTTL := zp.defaultTTL, zp.hasTTL
+	if !hasTTL {
+		ttl = zp.lastTTL
+	}
+	for len(tokens) > 0 && !tokens[0].quoted {
+		if t, ok := parseTTL(tokens[0].text); ok {
+			ttl = t
+			tokens = tokens[1:]
+			continue
+		}
+		if strings.EqualFold(tokens[0].text, "IN") {
+			tokens = tokens[1:]
+			continue
+		}
+		break
+	}
+	zp.lastTTL = ttl
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("record has no type")
+	}
+	recType := strings.ToUpper(tokens[0].text)
+	rdata := tokens[1:]
+
+	if recType == "SOA" || (recType == "NS" && name == "@") {
+		return nil, nil
+	}
+
+	return zp.buildRecord(name, ttl, recType, rdata)
+}
+
+// buildRecord converts the data of a zone file record into a record.
+func (zp *zoneParser) buildRecord(name string, ttl time.Duration, recType string, rdata []zoneToken) (libdns.Record, error) {
+	texts := make([]string, len(rdata))
+	for i, t := range rdata {
+		texts[i] = t.text
+	}
+
+	wantFields := func(n int) error {
+		if len(rdata) != n {
+			return fmt.Errorf("%s record requires %d fields, found %d", recType, n, len(rdata))
+		}
+		return nil
+	}
+	target := func(s string) string {
+		return zp.absolute(s)
+	}
+
+	rr := libdns.RR{Name: name, TTL: ttl, Type: recType}
+
+	switch recType {
+	case "A", "AAAA":
+		if err := wantFields(1); err != nil {
+			return nil, err
+		}
+		rr.Data = texts[0]
+	case "CNAME", "NS", "PTR", "ANAME", "DNAME":
+		if err := wantFields(1); err != nil {
+			return nil, err
+		}
+		rr.Data = target(texts[0])
+	case "MX":
+		if err := wantFields(2); err != nil {
+			return nil, err
+		}
+		rr.Data = texts[0] + " " + target(texts[1])
+	case "SRV":
+		if err := wantFields(4); err != nil {
+			return nil, err
+		}
+		rr.Data = strings.Join(texts[:3], " ") + " " + target(texts[3])
+	case "TXT":
+		if len(rdata) == 0 {
+			return nil, fmt.Errorf("TXT record requires at least one string")
+		}
+		return libdns.TXT{Name: name, TTL: ttl, Text: strings.Join(texts, "")}, nil
+	case "CAA":
+		if err := wantFields(3); err != nil {
+			return nil, err
+		}
+		flags, err := strconv.ParseUint(texts[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CAA flags %s", texts[0])
+		}
+		return libdns.CAA{Name: name, TTL: ttl, Flags: uint8(flags), Tag: texts[1], Value: texts[2]}, nil
+	case "SSHFP":
+		if len(rdata) < 3 {
+			return nil, fmt.Errorf("SSHFP record requires 3 fields, found %d", len(rdata))
+		}
+		rr.Data = strings.Join(texts[:2], " ") + " " + strings.Join(texts[2:], "")
+	case "TLSA":
+		if len(rdata) < 4 {
+			return nil, fmt.Errorf("TLSA record requires 4 fields, found %d", len(rdata))
+		}
+		rr.Data = strings.Join(texts[:3], " ") + " " + strings.Join(texts[3:], "")
+	case "HTTPS", "SVCB":
+		if len(rdata) < 2 {
+			return nil, fmt.Errorf("%s record requires at least 2 fields, found %d", recType, len(rdata))
+		}
+		// SvcParams are passed on as written, as their values may be quoted
+		// and escaped like the data of an RR.
+		fields := []string{texts[0], target(texts[1])}
+		for _, t := range rdata[2:] {
+			fields = append(fields, t.raw)
+		}
+		rr.Data = strings.Join(fields, " ")
+	case "DS":
+		if len(rdata) < 4 {
+			return nil, fmt.Errorf("DS record requires 4 fields, found %d", len(rdata))
+		}
+		rr.Data = strings.Join(texts[:3], " ") + " " + strings.Join(texts[3:], "")
+	default:
+		return nil, fmt.Errorf("unsupported record type %s", recType)
+	}
+
+	record, err := parseRR(rr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record: %w", recType, err)
+	}
+	return record, nil
+}
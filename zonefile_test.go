@@ -0,0 +1,245 @@
+package mythicbeasts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/libdns/mythicbeasts"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestImportReplaceKeepsNonUserRecords(t *testing.T) {
+	fingerprint := strings.Repeat("ab", 32)
+	digest := strings.Repeat("cd", 32)
+
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com",
+		mythicbeaststest.Record{Host: "host", Type: "SSHFP", Data: fingerprint, TTL: 300, SSHFPAlgorithm: 4, SSHFPType: 2, Template: true},
+		mythicbeaststest.Record{Host: "child", Type: "DS", Data: digest, TTL: 300, DSKeyTag: 12345, DSAlgorithm: 13, DSDigestType: 2, Generated: true},
+		mythicbeaststest.Record{Host: "old", Type: "SSHFP", Data: fingerprint, TTL: 300, SSHFPAlgorithm: 4, SSHFPType: 2},
+	)
+
+	zoneFile := "www 300 IN A 192.0.2.1\n"
+	_, err := newProvider(srv).ImportZone(context.Background(), "example.com.", strings.NewReader(zoneFile), mythicbeasts.ImportReplace)
+	if err != nil {
+		t.Fatalf("ImportZone: %v", err)
+	}
+
+	want := map[string]bool{"host SSHFP": true, "child DS": true, "www A": true}
+	records := srv.Records("example.com")
+	for _, r := range records {
+		if !want[r.Host+" "+r.Type] {
+			t.Errorf("zone still holds %s %s", r.Host, r.Type)
+		}
+		delete(want, r.Host+" "+r.Type)
+	}
+	for missing := range want {
+		t.Errorf("zone lost %s", missing)
+	}
+}
+
+// exportZone holds a record of each supported type.
+var exportZone = []mythicbeaststest.Record{
+	{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+	{Host: "www", Type: "AAAA", Data: "2001:db8::1", TTL: 300},
+	{Host: "@", Type: "MX", Data: "mail.example.com.", TTL: 3600, MXPriority: 10},
+	{Host: "@", Type: "TXT", Data: `v=spf1 include:"x" -all`, TTL: 300},
+	{Host: "@", Type: "CAA", Data: "letsencrypt.org", TTL: 300, CAATag: "issue"},
+	{Host: "_sip._tcp", Type: "SRV", Data: "sip.example.com.", TTL: 300, SRVPriority: 10, SRVWeight: 5, SRVPort: 5060},
+	{Host: "ftp", Type: "CNAME", Data: "www.example.com.", TTL: 300},
+	{Host: "@", Type: "ANAME", Data: "lb.example.net.", TTL: 300},
+	{Host: "@", Type: "HTTPS", Data: ".", TTL: 300, SVCBPriority: 1, SVCBParams: "alpn=h2,h3"},
+	{Host: "host", Type: "SSHFP", Data: "abababababababababababababababababababababababababababababababab", TTL: 300, SSHFPAlgorithm: 4, SSHFPType: 2},
+}
+
+func TestExportZone(t *testing.T) {
+	want := `$ORIGIN example.com.
+$TTL 300
+@	300	IN	ANAME	lb.example.net.
+@	300	IN	CAA	0 issue "letsencrypt.org"
+@	300	IN	HTTPS	1 . alpn=h2,h3
+@	3600	IN	MX	10 mail.example.com.
+@	300	IN	TXT	"v=spf1 include:\"x\" -all"
+_sip._tcp	300	IN	SRV	10 5 5060 sip.example.com.
+ftp	300	IN	CNAME	www.example.com.
+host	300	IN	SSHFP	4 2 abababababababababababababababababababababababababababababababab
+www	300	IN	A	192.0.2.1
+www	300	IN	AAAA	2001:db8::1
+`
+
+	// The output must not depend on the order the API lists records in.
+	reversed := make([]mythicbeaststest.Record, len(exportZone))
+	for i, r := range exportZone {
+		reversed[len(reversed)-1-i] = r
+	}
+
+	for _, records := range [][]mythicbeaststest.Record{exportZone, reversed} {
+		srv := mythicbeaststest.NewServer()
+		srv.AddZone("example.com", records...)
+
+		var buf bytes.Buffer
+		err := newProvider(srv).ExportZone(context.Background(), "example.com.", &buf)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("ExportZone: %v", err)
+		}
+		if buf.String() != want {
+			t.Errorf("ExportZone wrote\n%s\nwant\n%s", buf.String(), want)
+		}
+	}
+}
+
+func TestImportZone(t *testing.T) {
+	existing := []mythicbeaststest.Record{
+		{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+		{Host: "old", Type: "TXT", Data: "stale", TTL: 300},
+		{Host: "@", Type: "NS", Data: "ns1.mythic-beasts.com.", TTL: 300, Template: true},
+	}
+
+	zoneFile := `$ORIGIN example.com.
+$TTL 600
+@	IN	SOA	ns1.mythic-beasts.com. hostmaster.example.com. ( 1 3600 600 86400 300 )
+@	IN	NS	ns2.example.net.
+www	300	IN	A	192.0.2.1
+	IN	AAAA	2001:db8::1 ; inherits the owner name
+mail	IN	MX	10 mail.example.com.
+`
+
+	tests := []struct {
+		name      string
+		mode      mythicbeasts.ImportMode
+		wantAdded int
+		wantHosts []string // Host and type of each record left in the zone
+	}{
+		{
+			name:      "merge",
+			mode:      mythicbeasts.ImportMerge,
+			wantAdded: 2,
+			wantHosts: []string{"@ NS", "mail MX", "old TXT", "www A", "www AAAA"},
+		},
+		{
+			name:      "replace",
+			mode:      mythicbeasts.ImportReplace,
+			wantAdded: 3,
+			wantHosts: []string{"@ NS", "mail MX", "www A", "www AAAA"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mythicbeaststest.NewServer()
+			defer srv.Close()
+			srv.AddZone("example.com", existing...)
+
+			written, err := newProvider(srv).ImportZone(context.Background(), "example.com.", strings.NewReader(zoneFile), tt.mode)
+			if err != nil {
+				t.Fatalf("ImportZone: %v", err)
+			}
+			if len(written) != tt.wantAdded {
+				t.Errorf("wrote %d records, want %d", len(written), tt.wantAdded)
+			}
+
+			var hosts []string
+			for _, r := range srv.Records("example.com") {
+				hosts = append(hosts, r.Host+" "+r.Type)
+				if r.Host == "www" && r.Type == "AAAA" && r.TTL != 600 {
+					t.Errorf("www AAAA has TTL %d, want the $TTL of 600", r.TTL)
+				}
+			}
+			sort.Strings(hosts)
+			if !reflect.DeepEqual(hosts, tt.wantHosts) {
+				t.Errorf("zone holds %q, want %q", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestImportZoneQuotedSvcParams(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	zoneFile := `$ORIGIN example.com.
+@	300	IN	HTTPS	1 . alpn="h2,h3"
+_dns.svc	300	IN	SVCB	1 svc.example.net. mandatory=alpn alpn="h2" port="8443"
+`
+	_, err := newProvider(srv).ImportZone(context.Background(), "example.com.", strings.NewReader(zoneFile), mythicbeasts.ImportMerge)
+	if err != nil {
+		t.Fatalf("ImportZone: %v", err)
+	}
+
+	want := map[string]string{
+		"@ HTTPS":       "alpn=h2,h3",
+		"_dns.svc SVCB": "mandatory=alpn alpn=h2 port=8443",
+	}
+	records := srv.Records("example.com")
+	if len(records) != len(want) {
+		t.Fatalf("zone holds %+v, want %d records", records, len(want))
+	}
+	for _, r := range records {
+		if params := want[r.Host+" "+r.Type]; r.SVCBParams != params {
+			t.Errorf("%s %s has SvcParams %q, want %q", r.Host, r.Type, r.SVCBParams, params)
+		}
+	}
+}
+
+func TestImportZoneReportsEveryBadLine(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	zoneFile := `www	300	IN	A	192.0.2.1
+bad	300	IN	A	not-an-address
+mx	300	IN	MX	mail.example.com.
+ok	300	IN	TXT	"fine"
+`
+	_, err := newProvider(srv).ImportZone(context.Background(), "example.com.", strings.NewReader(zoneFile), mythicbeasts.ImportMerge)
+
+	var importErr *mythicbeasts.ImportError
+	if !errors.As(err, &importErr) || !errors.Is(err, mythicbeasts.ErrValidation) {
+		t.Fatalf("ImportZone returned %v, want an *ImportError", err)
+	}
+	var lines []int
+	for _, l := range importErr.Lines {
+		lines = append(lines, l.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 3}) {
+		t.Errorf("reported lines %v, want [2 3]", lines)
+	}
+	if records := srv.Records("example.com"); len(records) != 0 {
+		t.Errorf("zone holds %+v, want nothing written", records)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", exportZone...)
+	srv.AddZone("example.org")
+
+	provider := newProvider(srv)
+	var exported bytes.Buffer
+	if err := provider.ExportZone(context.Background(), "example.com.", &exported); err != nil {
+		t.Fatalf("ExportZone: %v", err)
+	}
+
+	// Names are relative, so the export can be loaded into another zone.
+	zoneFile := strings.Replace(exported.String(), "$ORIGIN example.com.", "$ORIGIN example.org.", 1)
+	if _, err := provider.ImportZone(context.Background(), "example.org.", strings.NewReader(zoneFile), mythicbeasts.ImportMerge); err != nil {
+		t.Fatalf("ImportZone: %v", err)
+	}
+
+	var reexported bytes.Buffer
+	if err := provider.ExportZone(context.Background(), "example.org.", &reexported); err != nil {
+		t.Fatalf("ExportZone: %v", err)
+	}
+	if reexported.String() != zoneFile {
+		t.Errorf("round trip wrote\n%s\nwant\n%s", reexported.String(), zoneFile)
+	}
+}
@@ -0,0 +1,161 @@
+package mythicbeasts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostedZone identifies the zone at Mythic Beasts that serves a requested
+// zone name. When the caller asks for a name below a hosted zone (for
+// example "www.example.com" while "example.com" is hosted), Prefix holds
+// the labels in between and record names are rewritten accordingly.
+type hostedZone struct {
+	Name   string // The zone as known to the API, without trailing dot
+	Prefix string // Labels of the requested zone below Name, or ""
+}
+
+// host converts a record name relative to the requested zone into a host
+// relative to the hosted zone.
+func (z hostedZone) host(name string) string {
+	if z.Prefix == "" {
+		return name
+	}
+	if name == "" || name == "@" {
+		return z.Prefix
+	}
+	return name + "." + z.Prefix
+}
+
+// name converts a host relative to the hosted zone into a record name
+// relative to the requested zone. It reports false if host lies outside
+// the requested zone.
+func (z hostedZone) name(host string) (string, bool) {
+	if z.Prefix == "" {
+		return host, true
+	}
+	lower := strings.ToLower(host)
+	if lower == z.Prefix {
+		return "@", true
+	}
+	if strings.HasSuffix(lower, "."+z.Prefix) {
+		return host[:len(host)-len(z.Prefix)-1], true
+	}
+	return "", false
+}
+
+// toHosts rewrites the names of records relative to the hosted zone.
+func (z hostedZone) toHosts(records []mythicRecordType) {
+	for i, r := range records {
+		records[i] = r.withName(z.host(r.GetName()))
+	}
+}
+
+// fromHosts rewrites the names of records relative to the requested zone,
+// dropping any records which lie outside of it.
+func (z hostedZone) fromHosts(records []mythicRecordType) []mythicRecordType {
+	var result []mythicRecordType
+	for _, r := range records {
+		name, ok := z.name(r.GetName())
+		if !ok {
+			continue
+		}
+		result = append(result, r.withName(name))
+	}
+	return result
+}
+
+// matchZone returns the longest zone in zones which zone is equal to or
+// below of.
+func matchZone(zones []string, zone string) (hostedZone, bool) {
+	var best hostedZone
+	found := false
+	for _, candidate := range zones {
+		if len(candidate) <= len(best.Name) && found {
+			continue
+		}
+		if zone == candidate {
+			best, found = hostedZone{Name: candidate}, true
+		} else if strings.HasSuffix(zone, "."+candidate) {
+			best, found = hostedZone{
+				Name:   candidate,
+				Prefix: strings.TrimSuffix(zone, "."+candidate),
+			}, true
+		}
+	}
+	return best, found
+}
+
+// defaultZoneCacheTTL is how long a zone list is relied on for names below
+// a hosted zone, unless set by ZoneCacheTTL.
+const defaultZoneCacheTTL = 5 * time.Minute
+
+// zoneCacheTTL returns how long a zone list is relied on for names below a
+// hosted zone.
+func (p *Provider) zoneCacheTTL() time.Duration {
+	if p.ZoneCacheTTL > 0 {
+		return p.ZoneCacheTTL
+	}
+	return defaultZoneCacheTTL
+}
+
+// resolveZone finds the hosted zone serving zone by matching it against the
+// zones in the account. The zone list is cached, and refreshed when no cached
+// zone matches, or when only a parent zone matches and the list is older than
+// ZoneCacheTTL, since a zone added to the account since it was listed may be
+// a better match.
+func (p *Provider) resolveZone(ctx context.Context, zone string) (hostedZone, error) {
+	zone = strings.ToLower(p.unFQDN(zone))
+	if zone == "" {
+		return hostedZone{}, fmt.Errorf("resolveZone: empty zone name")
+	}
+
+	p.mutex.Lock()
+	hosted, ok := matchZone(p.zones, zone)
+	fresh := time.Since(p.zonesAt) < p.zoneCacheTTL()
+	p.mutex.Unlock()
+	if ok && (hosted.Prefix == "" || fresh) {
+		return hosted, nil
+	}
+
+	listed, err := p.ListZones(ctx)
+	if err != nil {
+		return hostedZone{}, fmt.Errorf("resolveZone: %w", err)
+	}
+
+	zones := make([]string, 0, len(listed))
+	for _, z := range listed {
+		zones = append(zones, strings.ToLower(p.unFQDN(z.Name)))
+	}
+
+	p.mutex.Lock()
+	p.zones, p.zonesAt = zones, time.Now()
+	p.mutex.Unlock()
+
+	hosted, ok = matchZone(zones, zone)
+	if !ok {
+		return hostedZone{}, fmt.Errorf("resolveZone: zone %s is not hosted by this account: %w", zone, ErrZoneNotFound)
+	}
+	return hosted, nil
+}
+
+// lockZone serialises writes to a hosted zone, so that concurrent changes
+// and the reads which verify them do not interleave. Writes to different
+// zones, and all reads, proceed in parallel. It returns the unlock function.
+func (p *Provider) lockZone(zone string) func() {
+	p.mutex.Lock()
+	if p.zoneLocks == nil {
+		p.zoneLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := p.zoneLocks[zone]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.zoneLocks[zone] = lock
+	}
+	p.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
@@ -0,0 +1,240 @@
+package mythicbeasts_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/mythicbeasts/mythicbeaststest"
+)
+
+func TestResolveZoneFindsNewChildZone(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	provider.ZoneCacheTTL = 50 * time.Millisecond
+	ctx := context.Background()
+	record := []libdns.Record{libdns.TXT{Name: "www", Text: "hello"}}
+
+	// Before lab.example.com is hosted, its names are written to the parent.
+	if _, err := provider.AppendRecords(ctx, "lab.example.com.", record); err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if records := srv.Records("example.com"); len(records) != 1 || records[0].Host != "www.lab" {
+		t.Fatalf("example.com holds %+v, want www.lab", records)
+	}
+
+	// Once it is hosted, the cached parent must not be used after the
+	// cache expires.
+	srv.AddZone("lab.example.com")
+	time.Sleep(2 * provider.ZoneCacheTTL)
+	if _, err := provider.AppendRecords(ctx, "lab.example.com.", record); err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if records := srv.Records("lab.example.com"); len(records) != 1 || records[0].Host != "www" {
+		t.Errorf("lab.example.com holds %+v, want www", records)
+	}
+}
+
+func TestResolveZoneCachesParentMatches(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	provider := newProvider(srv)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.GetRecords(context.Background(), "www.example.com."); err != nil {
+			t.Fatalf("GetRecords: %v", err)
+		}
+	}
+
+	// The zones are listed once, and the records fetched each time.
+	if requests := srv.Requests(); requests != 4 {
+		t.Errorf("made %d requests, want 4", requests)
+	}
+}
+
+// requestZone returns the zone a DNS API request is for, or "".
+func requestZone(r *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/dns/v2/"), "/")
+	if len(parts) < 2 || parts[0] != "zones" {
+		return ""
+	}
+	return parts[1]
+}
+
+func isWrite(r *http.Request) bool {
+	return r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete
+}
+
+func TestWritesToDifferentZonesRunInParallel(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+	srv.AddZone("example.net")
+
+	// Hold each write until a write to the other zone arrives, which can
+	// only happen if they are not serialised.
+	arrived := map[string]chan struct{}{
+		"example.com": make(chan struct{}),
+		"example.net": make(chan struct{}),
+	}
+	var parallel atomic.Bool
+	px := newProxy(t, srv, func(r *http.Request) {
+		zone := requestZone(r)
+		if !isWrite(r) || arrived[zone] == nil {
+			return
+		}
+		close(arrived[zone])
+		other := "example.net"
+		if zone == other {
+			other = "example.com"
+		}
+		select {
+		case <-arrived[other]:
+			parallel.Store(true)
+		case <-time.After(2 * time.Second):
+		}
+	})
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+
+	var wg sync.WaitGroup
+	for _, zone := range []string{"example.com.", "example.net."} {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+			_, err := provider.AppendRecords(context.Background(), zone, []libdns.Record{libdns.TXT{Name: "www", Text: zone}})
+			if err != nil {
+				t.Errorf("AppendRecords(%s): %v", zone, err)
+			}
+		}(zone)
+	}
+	wg.Wait()
+
+	if !parallel.Load() {
+		t.Errorf("writes to different zones were serialised")
+	}
+}
+
+func TestWritesToOneZoneAreSerialised(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com")
+
+	// Count the writes in flight, holding each briefly so that any overlap
+	// is seen.
+	var inFlight, maxInFlight atomic.Int32
+	px := newProxy(t, srv, func(r *http.Request) {
+		if !isWrite(r) {
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	})
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+
+	// Each SetRecords replaces the same RRset, and must read back its own
+	// value rather than one written by another goroutine in between.
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			text := fmt.Sprintf("writer %d", i)
+			set, err := provider.SetRecords(context.Background(), "example.com.", []libdns.Record{libdns.TXT{Name: "www", Text: text}})
+			if err != nil {
+				t.Errorf("SetRecords %d: %v", i, err)
+				return
+			}
+			if len(set) != 1 || set[0].RR().Data != text {
+				t.Errorf("SetRecords %d returned %+v, want only %q", i, set, text)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if max := maxInFlight.Load(); max != 1 {
+		t.Errorf("%d writes to one zone were in flight at once, want 1", max)
+	}
+	if records := srv.Records("example.com"); len(records) != 1 {
+		t.Errorf("zone holds %+v, want a single record", records)
+	}
+}
+
+func TestReadsRunDuringWrite(t *testing.T) {
+	srv := mythicbeaststest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", mythicbeaststest.Record{Host: "www", Type: "A", Data: "192.0.2.1", TTL: 300})
+
+	// Hold the write until a read of the whole zone arrives.
+	writing := make(chan struct{})
+	read := make(chan struct{})
+	var readDuringWrite atomic.Bool
+	px := newProxy(t, srv, func(r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			close(writing)
+			select {
+			case <-read:
+				readDuringWrite.Store(true)
+			case <-time.After(2 * time.Second):
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/dns/v2/zones/example.com/records":
+			close(read)
+		}
+	})
+	defer px.Close()
+
+	provider := newProvider(srv)
+	provider.APIURL = px.URL + "/dns/v2"
+	ctx := context.Background()
+
+	// Resolve the zone first, so that both calls go straight to the records.
+	if _, err := provider.GetRecordsFiltered(ctx, "example.com.", "www", "A"); err != nil {
+		t.Fatalf("GetRecordsFiltered: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := provider.AppendRecords(ctx, "example.com.", []libdns.Record{libdns.TXT{Name: "www", Text: "hello"}})
+		if err != nil {
+			t.Errorf("AppendRecords: %v", err)
+		}
+	}()
+
+	<-writing
+	records, err := provider.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Errorf("GetRecords: %v", err)
+	} else if len(records) == 0 {
+		t.Errorf("GetRecords returned no records")
+	}
+	<-done
+
+	if !readDuringWrite.Load() {
+		t.Errorf("GetRecords waited for the write to finish")
+	}
+}